@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+	"github.com/mikegio27/proc-dungeons/save"
+	"github.com/mikegio27/proc-dungeons/tiled"
+	"github.com/mikegio27/proc-dungeons/vtt"
+)
+
+// newFlagSet returns a FlagSet that reports errors instead of exiting, so
+// run can turn them into exit codes.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseConfig parses args into a seed and config using the shared config
+// flags plus any command specific flags already registered on fs.
+func parseConfig(fs *flag.FlagSet, args []string) (int64, generator.Config, error) {
+	cf := addConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 0, generator.Config{}, err
+	}
+	cf.collect(fs)
+	cfg, err := cf.Config()
+	if err != nil {
+		return 0, generator.Config{}, err
+	}
+	return cf.Seed(), cfg, nil
+}
+
+// parseValidConfig is parseConfig followed by Config.Validate, for the
+// commands that go on to generate.
+func parseValidConfig(fs *flag.FlagSet, args []string) (int64, generator.Config, error) {
+	seed, cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return 0, generator.Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return 0, generator.Config{}, err
+	}
+	return seed, cfg, nil
+}
+
+// generate runs g and returns the dungeon with its connectivity report.
+// Unreachable rooms are reported on stderr but do not fail the command,
+// since the dungeon is still usable.
+func generate(ctx context.Context, g *generator.Generator, stderr io.Writer) (model.Dungeon, generator.ConnectivityReport, error) {
+	d, err := g.Generate(ctx)
+	if errors.Is(err, generator.ErrUnreachableRoom) {
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+		err = nil
+	}
+	return d, g.Connectivity(), err
+}
+
+// exitCode maps a command error to a process exit code, printing it when
+// it is not a plain -h request.
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil {
+		return 0
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintf(stderr, "error: %v\n", err)
+	return 1
+}
+
+func runGenerate(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("generate", stderr)
+	format := fs.String("format", "ascii", "output format: ascii, json, png, gif, svg, tmx, tmj or dd2vtt")
+	trace := fs.Bool("trace", false, "log every generation event to stderr")
+	rf := addRenderFlags(fs)
+	imf := addImageFlags(fs)
+	tf := addTiledFlags(fs)
+	seed, cfg, err := parseValidConfig(fs, args)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	opts, err := rf.Options(stdout)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	imgOpts, err := imf.ImageOptions(opts.Theme)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	svgOpts := imf.SVGOptions(opts.Theme)
+	vttOpts, err := imf.VTTOptions(imgOpts)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	tiledOpts, err := tf.Options()
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+
+	g := generator.New(cfg, seed)
+	if *trace {
+		g.Observe(generator.ObserverFunc(func(e generator.Event) { fmt.Fprintln(stderr, e) }))
+	}
+	// gif records a frame after every generation step.
+	var anim *render.Animation
+	if *format == "gif" {
+		if anim, err = imf.Animation(imgOpts); err != nil {
+			return exitCode(err, stderr)
+		}
+		g.Record(func(_ generator.Step, d *model.Dungeon) { anim.AddFrame(d) })
+	}
+
+	fmt.Fprintf(stderr, "Using seed: %d\n", seed)
+	d, _, err := generate(ctx, g, stderr)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+
+	switch *format {
+	case "ascii":
+		if err := render.New(opts).Render(stdout, &d); err != nil {
+			return exitCode(err, stderr)
+		}
+		fmt.Fprintf(stdout, "Rooms: %v\n", d.Rooms)
+	case "png":
+		if err := render.WritePNG(stdout, &d, imgOpts); err != nil {
+			return exitCode(err, stderr)
+		}
+	case "gif":
+		anim.AddFrame(&d)
+		if err := anim.WriteGIF(stdout); err != nil {
+			return exitCode(err, stderr)
+		}
+	case "svg":
+		if err := render.WriteSVG(stdout, &d, svgOpts); err != nil {
+			return exitCode(err, stderr)
+		}
+	case "tmx":
+		if err := tiled.WriteTMX(stdout, &d, tiledOpts); err != nil {
+			return exitCode(err, stderr)
+		}
+	case "tmj":
+		if err := tiled.WriteTMJ(stdout, &d, tiledOpts); err != nil {
+			return exitCode(err, stderr)
+		}
+	case "dd2vtt":
+		if err := vtt.Write(stdout, &d, vttOpts); err != nil {
+			return exitCode(err, stderr)
+		}
+	case "json":
+		if err := save.Write(stdout, save.New(seed, cfg, d)); err != nil {
+			return exitCode(err, stderr)
+		}
+	default:
+		return exitCode(fmt.Errorf("unknown format %q", *format), stderr)
+	}
+	return 0
+}
+
+func runRender(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("render", stderr)
+	rf := addRenderFlags(fs)
+	seed, cfg, err := parseValidConfig(fs, args)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	opts, err := rf.Options(stdout)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+
+	d, _, err := generate(ctx, generator.New(cfg, seed), stderr)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	return exitCode(render.New(opts).Render(stdout, &d), stderr)
+}
+
+func runStats(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("stats", stderr)
+	seed, cfg, err := parseValidConfig(fs, args)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+
+	d, report, err := generate(ctx, generator.New(cfg, seed), stderr)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	printStats(stdout, seed, &d, report)
+	return 0
+}
+
+func runValidate(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("validate", stderr)
+	gen := fs.Bool("generate", false, "also generate a dungeon from the config and check its invariants")
+	saved := fs.String("dungeon", "", "check the invariants of a saved dungeon (from generate -format json) instead")
+	seed, cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+
+	if *saved != "" {
+		f, err := save.Load(*saved)
+		if err != nil {
+			return exitCode(err, stderr)
+		}
+		return checkDungeon(&f.Dungeon, stdout, stderr)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var verr generator.ValidationError
+		if errors.As(err, &verr) {
+			for _, fe := range verr {
+				fmt.Fprintln(stdout, fe)
+			}
+			return 1
+		}
+		return exitCode(err, stderr)
+	}
+	fmt.Fprintln(stdout, "config ok")
+	if !*gen {
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "Using seed: %d\n", seed)
+	d, _, err := generate(ctx, generator.New(cfg, seed), stderr)
+	if err != nil {
+		return exitCode(err, stderr)
+	}
+	return checkDungeon(&d, stdout, stderr)
+}
+
+// checkDungeon prints every invariant d violates and returns the exit
+// code for validate.
+func checkDungeon(d *model.Dungeon, stdout, stderr io.Writer) int {
+	if err := d.Validate(); err != nil {
+		var vs model.Violations
+		if errors.As(err, &vs) {
+			for _, v := range vs {
+				fmt.Fprintln(stdout, v)
+			}
+			return 1
+		}
+		return exitCode(err, stderr)
+	}
+	fmt.Fprintln(stdout, "dungeon ok")
+	return 0
+}
+
+func printStats(w io.Writer, seed int64, d *model.Dungeon, report generator.ConnectivityReport) {
+	fmt.Fprintf(w, "seed:  %d\n", seed)
+	fmt.Fprintf(w, "grid:  %dx%d (%d..%d, %d..%d)\n",
+		d.Grid.Width(), d.Grid.Height(), d.Grid.MinX, d.Grid.MaxX, d.Grid.MinY, d.Grid.MaxY)
+	fmt.Fprintf(w, "rooms: %d\n", len(d.Rooms))
+
+	shapes := make(map[model.RoomId]int)
+	for _, r := range d.Rooms {
+		shapes[r.Shape]++
+	}
+	for _, id := range []model.RoomId{model.Rectangle, model.Circle, model.Square, model.Triangle} {
+		if n := shapes[id]; n > 0 {
+			fmt.Fprintf(w, "  %-10s %d\n", id, n)
+		}
+	}
+
+	tiles := make(map[model.Tile]int)
+	for _, t := range d.Tiles {
+		tiles[t]++
+	}
+	total := len(d.Tiles)
+	fmt.Fprintln(w, "tiles:")
+	for _, t := range []model.Tile{model.TileRoomFloor, model.TileCorridor, model.TileDoor, model.TileWall, model.TileEmpty} {
+		fmt.Fprintf(w, "  %-10s %6d  %5.1f%%\n", t, tiles[t], 100*float64(tiles[t])/float64(max(total, 1)))
+	}
+	fmt.Fprintf(w, "starts: %v\n", d.Starts)
+	fmt.Fprintf(w, "unreachable after carving: %d, repaired: %d, still unreachable: %d\n",
+		len(report.Unreachable), len(report.Repairs), len(report.Failed))
+	for _, a := range report.Repairs {
+		fmt.Fprintf(w, "  %s\n", a)
+	}
+	fmt.Fprintf(w, "fingerprint: %s\n", d.Fingerprint())
+}
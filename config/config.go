@@ -0,0 +1,536 @@
+// Package config loads generator.Config from versioned JSON, TOML or YAML
+// files.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Version is the config file schema version this package reads and writes.
+const Version = 1
+
+// File is the on-disk layout of a config file. Every field except Version
+// is optional; fields left out keep the value of the Config they are
+// applied to.
+type File struct {
+	Version int    `json:"version" toml:"version" yaml:"version"`
+	Seed    *int64 `json:"seed,omitempty" toml:"seed,omitempty" yaml:"seed,omitempty"`
+
+	Mode *generator.Mode `json:"mode,omitempty" toml:"mode,omitempty" yaml:"mode,omitempty"`
+
+	Grid *Grid `json:"grid,omitempty" toml:"grid,omitempty" yaml:"grid,omitempty"`
+
+	MaxRooms   *int           `json:"max_rooms,omitempty" toml:"max_rooms,omitempty" yaml:"max_rooms,omitempty"`
+	RoomShapes []model.RoomId `json:"room_shapes,omitempty" toml:"room_shapes,omitempty" yaml:"room_shapes,omitempty"`
+
+	RoomMinW *int32 `json:"room_min_w,omitempty" toml:"room_min_w,omitempty" yaml:"room_min_w,omitempty"`
+	RoomMaxW *int32 `json:"room_max_w,omitempty" toml:"room_max_w,omitempty" yaml:"room_max_w,omitempty"`
+	RoomMinH *int32 `json:"room_min_h,omitempty" toml:"room_min_h,omitempty" yaml:"room_min_h,omitempty"`
+	RoomMaxH *int32 `json:"room_max_h,omitempty" toml:"room_max_h,omitempty" yaml:"room_max_h,omitempty"`
+
+	CorridorWidth  *int32 `json:"corridor_width,omitempty" toml:"corridor_width,omitempty" yaml:"corridor_width,omitempty"`
+	CorridorBuffer *int32 `json:"corridor_buffer,omitempty" toml:"corridor_buffer,omitempty" yaml:"corridor_buffer,omitempty"`
+
+	MaxRoomArea      *float64             `json:"max_room_area,omitempty" toml:"max_room_area,omitempty" yaml:"max_room_area,omitempty"`
+	MaxTotalRoomArea *float64             `json:"max_total_room_area,omitempty" toml:"max_total_room_area,omitempty" yaml:"max_total_room_area,omitempty"`
+	MinRoomGap       *int32               `json:"min_room_gap,omitempty" toml:"min_room_gap,omitempty" yaml:"min_room_gap,omitempty"`
+	Loops            *float64             `json:"loops,omitempty" toml:"loops,omitempty" yaml:"loops,omitempty"`
+	ShapeSizes       map[string]ShapeSize `json:"shape_sizes,omitempty" toml:"shape_sizes,omitempty" yaml:"shape_sizes,omitempty"`
+
+	MaxIterations *int      `json:"max_iterations,omitempty" toml:"max_iterations,omitempty" yaml:"max_iterations,omitempty"`
+	Timeout       *Duration `json:"timeout,omitempty" toml:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	Repair []generator.RepairStrategy `json:"repair,omitempty" toml:"repair,omitempty" yaml:"repair,omitempty"`
+
+	Cave *Cave `json:"cave,omitempty" toml:"cave,omitempty" yaml:"cave,omitempty"`
+	Walk *Walk `json:"walk,omitempty" toml:"walk,omitempty" yaml:"walk,omitempty"`
+	WFC  *WFC  `json:"wfc,omitempty" toml:"wfc,omitempty" yaml:"wfc,omitempty"`
+
+	Graph *Graph `json:"graph,omitempty" toml:"graph,omitempty" yaml:"graph,omitempty"`
+	Doors *Doors `json:"doors,omitempty" toml:"doors,omitempty" yaml:"doors,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "1.5s".
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// ShapeSize is the file form of generator.SizeRule, keyed by shape name in
+// File.ShapeSizes.
+type ShapeSize struct {
+	MinW    int32              `json:"min_w,omitempty" toml:"min_w,omitempty" yaml:"min_w,omitempty"`
+	MaxW    int32              `json:"max_w,omitempty" toml:"max_w,omitempty" yaml:"max_w,omitempty"`
+	MinH    int32              `json:"min_h,omitempty" toml:"min_h,omitempty" yaml:"min_h,omitempty"`
+	MaxH    int32              `json:"max_h,omitempty" toml:"max_h,omitempty" yaml:"max_h,omitempty"`
+	Dist    generator.SizeDist `json:"dist,omitempty" toml:"dist,omitempty" yaml:"dist,omitempty"`
+	Spread  float64            `json:"spread,omitempty" toml:"spread,omitempty" yaml:"spread,omitempty"`
+	Buckets []Bucket           `json:"buckets,omitempty" toml:"buckets,omitempty" yaml:"buckets,omitempty"`
+}
+
+// Bucket is the file form of generator.SizeBucket.
+type Bucket struct {
+	MinW   int32 `json:"min_w,omitempty" toml:"min_w,omitempty" yaml:"min_w,omitempty"`
+	MaxW   int32 `json:"max_w,omitempty" toml:"max_w,omitempty" yaml:"max_w,omitempty"`
+	MinH   int32 `json:"min_h,omitempty" toml:"min_h,omitempty" yaml:"min_h,omitempty"`
+	MaxH   int32 `json:"max_h,omitempty" toml:"max_h,omitempty" yaml:"max_h,omitempty"`
+	Weight int   `json:"weight" toml:"weight" yaml:"weight"`
+}
+
+// Doors is the file form of generator.DoorConfig, with Counts keyed by
+// shape name.
+type Doors struct {
+	Counts  map[string]DoorCount `json:"counts,omitempty" toml:"counts,omitempty" yaml:"counts,omitempty"`
+	Spacing *int32               `json:"spacing,omitempty" toml:"spacing,omitempty" yaml:"spacing,omitempty"`
+}
+
+// DoorCount is the file form of generator.DoorRange.
+type DoorCount struct {
+	Min int32 `json:"min,omitempty" toml:"min,omitempty" yaml:"min,omitempty"`
+	Max int32 `json:"max,omitempty" toml:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// Cave is the file form of generator.CaveConfig.
+type Cave struct {
+	Fill      *float64 `json:"fill,omitempty" toml:"fill,omitempty" yaml:"fill,omitempty"`
+	Birth     *int     `json:"birth,omitempty" toml:"birth,omitempty" yaml:"birth,omitempty"`
+	Survival  *int     `json:"survival,omitempty" toml:"survival,omitempty" yaml:"survival,omitempty"`
+	Steps     *int     `json:"steps,omitempty" toml:"steps,omitempty" yaml:"steps,omitempty"`
+	Link      *bool    `json:"link,omitempty" toml:"link,omitempty" yaml:"link,omitempty"`
+	MinRegion *int     `json:"min_region,omitempty" toml:"min_region,omitempty" yaml:"min_region,omitempty"`
+	Ratio     *float64 `json:"ratio,omitempty" toml:"ratio,omitempty" yaml:"ratio,omitempty"`
+}
+
+// Walk is the file form of generator.WalkConfig.
+type Walk struct {
+	Walkers    *int     `json:"walkers,omitempty" toml:"walkers,omitempty" yaml:"walkers,omitempty"`
+	Steps      *int     `json:"steps,omitempty" toml:"steps,omitempty" yaml:"steps,omitempty"`
+	Turn       *float64 `json:"turn,omitempty" toml:"turn,omitempty" yaml:"turn,omitempty"`
+	Coverage   *float64 `json:"coverage,omitempty" toml:"coverage,omitempty" yaml:"coverage,omitempty"`
+	EdgeMargin *int32   `json:"edge_margin,omitempty" toml:"edge_margin,omitempty" yaml:"edge_margin,omitempty"`
+	Floor      *bool    `json:"floor,omitempty" toml:"floor,omitempty" yaml:"floor,omitempty"`
+}
+
+// WFC is the file form of generator.WFCConfig. Sample holds one string per
+// row from the top, drawn with the glyphs of model.Tile.Rune.
+type WFC struct {
+	Sample        []string `json:"sample,omitempty" toml:"sample,omitempty" yaml:"sample,omitempty"`
+	N             *int     `json:"n,omitempty" toml:"n,omitempty" yaml:"n,omitempty"`
+	Symmetry      *int     `json:"symmetry,omitempty" toml:"symmetry,omitempty" yaml:"symmetry,omitempty"`
+	PeriodicInput *bool    `json:"periodic_input,omitempty" toml:"periodic_input,omitempty" yaml:"periodic_input,omitempty"`
+	Periodic      *bool    `json:"periodic,omitempty" toml:"periodic,omitempty" yaml:"periodic,omitempty"`
+	Backtracks    *int     `json:"backtracks,omitempty" toml:"backtracks,omitempty" yaml:"backtracks,omitempty"`
+}
+
+// Graph is the file form of generator.RoomGraph, also read on its own by
+// LoadGraph.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes,omitempty" toml:"nodes,omitempty" yaml:"nodes,omitempty"`
+	Edges []GraphEdge `json:"edges,omitempty" toml:"edges,omitempty" yaml:"edges,omitempty"`
+}
+
+// GraphNode is the file form of generator.GraphNode.
+type GraphNode struct {
+	Name  string       `json:"name" toml:"name" yaml:"name"`
+	Shape model.RoomId `json:"shape,omitempty" toml:"shape,omitempty" yaml:"shape,omitempty"`
+	W     int32        `json:"w,omitempty" toml:"w,omitempty" yaml:"w,omitempty"`
+	H     int32        `json:"h,omitempty" toml:"h,omitempty" yaml:"h,omitempty"`
+	Tags  []string     `json:"tags,omitempty" toml:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// GraphEdge is the file form of generator.GraphEdge.
+type GraphEdge struct {
+	From string             `json:"from" toml:"from" yaml:"from"`
+	To   string             `json:"to" toml:"to" yaml:"to"`
+	Door generator.DoorKind `json:"door,omitempty" toml:"door,omitempty" yaml:"door,omitempty"`
+}
+
+// RoomGraph returns the graph g describes.
+func (g Graph) RoomGraph() generator.RoomGraph {
+	var rg generator.RoomGraph
+	for _, n := range g.Nodes {
+		n.Tags = append([]string(nil), n.Tags...)
+		rg.Nodes = append(rg.Nodes, generator.GraphNode(n))
+	}
+	for _, e := range g.Edges {
+		rg.Edges = append(rg.Edges, generator.GraphEdge(e))
+	}
+	return rg
+}
+
+// graphFile is the inverse of Graph.RoomGraph.
+func graphFile(rg generator.RoomGraph) *Graph {
+	if len(rg.Nodes) == 0 && len(rg.Edges) == 0 {
+		return nil
+	}
+	g := &Graph{}
+	for _, n := range rg.Nodes {
+		n.Tags = append([]string(nil), n.Tags...)
+		g.Nodes = append(g.Nodes, GraphNode(n))
+	}
+	for _, e := range rg.Edges {
+		g.Edges = append(g.Edges, GraphEdge(e))
+	}
+	return g
+}
+
+// Grid describes the grid bounds either explicitly or as a width and
+// height centred on the origin. Explicit bounds win over width and height.
+type Grid struct {
+	Width  *int32 `json:"width,omitempty" toml:"width,omitempty" yaml:"width,omitempty"`
+	Height *int32 `json:"height,omitempty" toml:"height,omitempty" yaml:"height,omitempty"`
+	MinX   *int32 `json:"min_x,omitempty" toml:"min_x,omitempty" yaml:"min_x,omitempty"`
+	MaxX   *int32 `json:"max_x,omitempty" toml:"max_x,omitempty" yaml:"max_x,omitempty"`
+	MinY   *int32 `json:"min_y,omitempty" toml:"min_y,omitempty" yaml:"min_y,omitempty"`
+	MaxY   *int32 `json:"max_y,omitempty" toml:"max_y,omitempty" yaml:"max_y,omitempty"`
+}
+
+// CenteredSpan returns the bounds of a span of n tiles centred on zero.
+func CenteredSpan(n int32) (lo, hi int32) {
+	lo = -(n / 2)
+	return lo, lo + n - 1
+}
+
+// Load reads the config file at path, choosing the decoder from its
+// extension (.json, .toml, .yaml or .yml).
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	f, err := Parse(data, filepath.Ext(path))
+	if err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// LoadGraph reads a room graph file at path, which holds the nodes and
+// edges keys of the graph section of a config file, choosing the decoder
+// as Load does.
+func LoadGraph(path string) (generator.RoomGraph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return generator.RoomGraph{}, err
+	}
+	var g Graph
+	if err := decode(data, filepath.Ext(path), &g); err != nil {
+		return generator.RoomGraph{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return g.RoomGraph(), nil
+}
+
+// decode decodes data in the format named by ext into v, rejecting
+// unknown keys.
+func decode(data []byte, ext string, v any) error {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	case "toml":
+		md, err := toml.Decode(string(data), v)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %q", undecoded[0].String())
+		}
+		return nil
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		return dec.Decode(v)
+	default:
+		return fmt.Errorf("unsupported config format %q", ext)
+	}
+}
+
+// Parse decodes data in the format named by ext and checks its version.
+// Unknown keys are rejected so typos do not silently fall back to defaults.
+func Parse(data []byte, ext string) (File, error) {
+	var f File
+	if err := decode(data, ext, &f); err != nil {
+		return File{}, err
+	}
+
+	switch {
+	case f.Version == 0:
+		return File{}, generator.ValidationError{{Field: "version", Msg: "is required"}}
+	case f.Version > Version:
+		return File{}, generator.ValidationError{{
+			Field: "version",
+			Msg:   fmt.Sprintf("%d is newer than the supported version %d", f.Version, Version),
+		}}
+	}
+	return f, nil
+}
+
+// Apply overwrites the fields of cfg that are present in the file. Entries
+// of ShapeSizes replace the rule for their shape only.
+func (f File) Apply(cfg *generator.Config) error {
+	if f.Mode != nil {
+		cfg.Mode = *f.Mode
+	}
+	if g := f.Grid; g != nil {
+		if g.Width != nil {
+			cfg.Grid.MinX, cfg.Grid.MaxX = CenteredSpan(*g.Width)
+		}
+		if g.Height != nil {
+			cfg.Grid.MinY, cfg.Grid.MaxY = CenteredSpan(*g.Height)
+		}
+		setValue(&cfg.Grid.MinX, g.MinX)
+		setValue(&cfg.Grid.MaxX, g.MaxX)
+		setValue(&cfg.Grid.MinY, g.MinY)
+		setValue(&cfg.Grid.MaxY, g.MaxY)
+	}
+	if f.MaxRooms != nil {
+		cfg.MaxRooms = *f.MaxRooms
+	}
+	if f.RoomShapes != nil {
+		cfg.RoomShapes = append([]model.RoomId(nil), f.RoomShapes...)
+	}
+	setValue(&cfg.RoomMinW, f.RoomMinW)
+	setValue(&cfg.RoomMaxW, f.RoomMaxW)
+	setValue(&cfg.RoomMinH, f.RoomMinH)
+	setValue(&cfg.RoomMaxH, f.RoomMaxH)
+	setValue(&cfg.CorridorW, f.CorridorWidth)
+	setValue(&cfg.CorridorBuff, f.CorridorBuffer)
+
+	if f.MaxRoomArea != nil {
+		cfg.MaxRoomAreaFraction = *f.MaxRoomArea
+	}
+	if f.MaxTotalRoomArea != nil {
+		cfg.MaxTotalRoomAreaFraction = *f.MaxTotalRoomArea
+	}
+	setValue(&cfg.MinRoomGap, f.MinRoomGap)
+	setValue(&cfg.Loops, f.Loops)
+	if f.MaxIterations != nil {
+		cfg.MaxIterations = *f.MaxIterations
+	}
+	if f.Timeout != nil {
+		cfg.Timeout = time.Duration(*f.Timeout)
+	}
+	if f.Repair != nil {
+		cfg.Repair = append([]generator.RepairStrategy{}, f.Repair...)
+	}
+	if cv := f.Cave; cv != nil {
+		setValue(&cfg.Cave.Fill, cv.Fill)
+		setValue(&cfg.Cave.Birth, cv.Birth)
+		setValue(&cfg.Cave.Survival, cv.Survival)
+		setValue(&cfg.Cave.Steps, cv.Steps)
+		setValue(&cfg.Cave.Link, cv.Link)
+		setValue(&cfg.Cave.MinRegion, cv.MinRegion)
+		setValue(&cfg.Cave.Ratio, cv.Ratio)
+	}
+	if w := f.Walk; w != nil {
+		setValue(&cfg.Walk.Walkers, w.Walkers)
+		setValue(&cfg.Walk.Steps, w.Steps)
+		setValue(&cfg.Walk.Turn, w.Turn)
+		setValue(&cfg.Walk.Coverage, w.Coverage)
+		setValue(&cfg.Walk.EdgeMargin, w.EdgeMargin)
+		setValue(&cfg.Walk.Floor, w.Floor)
+	}
+	if w := f.WFC; w != nil {
+		if w.Sample != nil {
+			sample, err := parseSample(w.Sample)
+			if err != nil {
+				return err
+			}
+			cfg.WFC.Sample = sample
+		}
+		setValue(&cfg.WFC.N, w.N)
+		setValue(&cfg.WFC.Symmetry, w.Symmetry)
+		setValue(&cfg.WFC.PeriodicInput, w.PeriodicInput)
+		setValue(&cfg.WFC.Periodic, w.Periodic)
+		setValue(&cfg.WFC.Backtracks, w.Backtracks)
+	}
+
+	if f.Graph != nil {
+		cfg.Graph = f.Graph.RoomGraph()
+	}
+
+	if f.Doors != nil {
+		setValue(&cfg.Doors.Spacing, f.Doors.Spacing)
+		for name, dc := range f.Doors.Counts {
+			shape, ok := model.ParseRoomId(name)
+			if !ok {
+				return generator.ValidationError{{
+					Field: "doors.counts." + name,
+					Msg:   "unknown room shape",
+				}}
+			}
+			if cfg.Doors.Counts == nil {
+				cfg.Doors.Counts = make(map[model.RoomId]generator.DoorRange)
+			}
+			cfg.Doors.Counts[shape] = generator.DoorRange(dc)
+		}
+	}
+
+	for name, ss := range f.ShapeSizes {
+		shape, ok := model.ParseRoomId(name)
+		if !ok {
+			return generator.ValidationError{{
+				Field: "shape_sizes." + name,
+				Msg:   "unknown room shape",
+			}}
+		}
+		if cfg.ShapeSizes == nil {
+			cfg.ShapeSizes = make(map[model.RoomId]generator.SizeRule)
+		}
+		rule := generator.SizeRule{
+			MinW:   ss.MinW,
+			MaxW:   ss.MaxW,
+			MinH:   ss.MinH,
+			MaxH:   ss.MaxH,
+			Dist:   ss.Dist,
+			Spread: ss.Spread,
+		}
+		for _, b := range ss.Buckets {
+			rule.Buckets = append(rule.Buckets, generator.SizeBucket(b))
+		}
+		cfg.ShapeSizes[shape] = rule
+	}
+	return nil
+}
+
+// FromConfig returns a File holding every field of cfg and the seed, such
+// that applying it to a zero Config gives back cfg.
+func FromConfig(seed int64, cfg generator.Config) File {
+	f := File{
+		Version:          Version,
+		Seed:             &seed,
+		Mode:             &cfg.Mode,
+		Grid:             &Grid{MinX: &cfg.Grid.MinX, MaxX: &cfg.Grid.MaxX, MinY: &cfg.Grid.MinY, MaxY: &cfg.Grid.MaxY},
+		MaxRooms:         &cfg.MaxRooms,
+		RoomShapes:       append([]model.RoomId(nil), cfg.RoomShapes...),
+		RoomMinW:         &cfg.RoomMinW,
+		RoomMaxW:         &cfg.RoomMaxW,
+		RoomMinH:         &cfg.RoomMinH,
+		RoomMaxH:         &cfg.RoomMaxH,
+		CorridorWidth:    &cfg.CorridorW,
+		CorridorBuffer:   &cfg.CorridorBuff,
+		MaxRoomArea:      &cfg.MaxRoomAreaFraction,
+		MaxTotalRoomArea: &cfg.MaxTotalRoomAreaFraction,
+		MinRoomGap:       &cfg.MinRoomGap,
+		Loops:            &cfg.Loops,
+		MaxIterations:    &cfg.MaxIterations,
+		Repair:           append([]generator.RepairStrategy(nil), cfg.Repair...),
+		Cave: &Cave{
+			Fill:      &cfg.Cave.Fill,
+			Birth:     &cfg.Cave.Birth,
+			Survival:  &cfg.Cave.Survival,
+			Steps:     &cfg.Cave.Steps,
+			Link:      &cfg.Cave.Link,
+			MinRegion: &cfg.Cave.MinRegion,
+			Ratio:     &cfg.Cave.Ratio,
+		},
+		Walk: &Walk{
+			Walkers:    &cfg.Walk.Walkers,
+			Steps:      &cfg.Walk.Steps,
+			Turn:       &cfg.Walk.Turn,
+			Coverage:   &cfg.Walk.Coverage,
+			EdgeMargin: &cfg.Walk.EdgeMargin,
+			Floor:      &cfg.Walk.Floor,
+		},
+		WFC: &WFC{
+			Sample:        formatSample(cfg.WFC.Sample),
+			N:             &cfg.WFC.N,
+			Symmetry:      &cfg.WFC.Symmetry,
+			PeriodicInput: &cfg.WFC.PeriodicInput,
+			Periodic:      &cfg.WFC.Periodic,
+			Backtracks:    &cfg.WFC.Backtracks,
+		},
+		Graph: graphFile(cfg.Graph),
+		Doors: &Doors{Spacing: &cfg.Doors.Spacing},
+	}
+	for shape, r := range cfg.Doors.Counts {
+		if f.Doors.Counts == nil {
+			f.Doors.Counts = make(map[string]DoorCount)
+		}
+		f.Doors.Counts[shape.String()] = DoorCount(r)
+	}
+	if cfg.Timeout != 0 {
+		timeout := Duration(cfg.Timeout)
+		f.Timeout = &timeout
+	}
+	for shape, rule := range cfg.ShapeSizes {
+		if f.ShapeSizes == nil {
+			f.ShapeSizes = make(map[string]ShapeSize)
+		}
+		ss := ShapeSize{
+			MinW:   rule.MinW,
+			MaxW:   rule.MaxW,
+			MinH:   rule.MinH,
+			MaxH:   rule.MaxH,
+			Dist:   rule.Dist,
+			Spread: rule.Spread,
+		}
+		for _, b := range rule.Buckets {
+			ss.Buckets = append(ss.Buckets, Bucket(b))
+		}
+		f.ShapeSizes[shape.String()] = ss
+	}
+	return f
+}
+
+func setValue[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// parseSample reads the rows of File.WFC.Sample.
+func parseSample(rows []string) ([][]model.Tile, error) {
+	sample := make([][]model.Tile, len(rows))
+	for i, row := range rows {
+		for _, r := range row {
+			t, ok := model.ParseRune(r)
+			if !ok {
+				return nil, generator.ValidationError{{
+					Field: fmt.Sprintf("wfc.sample[%d]", i),
+					Msg:   fmt.Sprintf("unknown glyph %q", r),
+				}}
+			}
+			sample[i] = append(sample[i], t)
+		}
+	}
+	return sample, nil
+}
+
+// formatSample is the inverse of parseSample.
+func formatSample(sample [][]model.Tile) []string {
+	var rows []string
+	for _, row := range sample {
+		var sb strings.Builder
+		for _, t := range row {
+			sb.WriteRune(t.Rune())
+		}
+		rows = append(rows, sb.String())
+	}
+	return rows
+}
@@ -0,0 +1,256 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikegio27/proc-dungeons/config"
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// The same config in each format, touching a field of every kind.
+const (
+	testTOML = `
+version = 1
+seed = 42
+mode = "bsp"
+room_shapes = ["rectangle", "circle"]
+corridor_width = 2
+timeout = "1.5s"
+repair = ["move-door", "drop-room"]
+
+[grid]
+width = 60
+min_y = -5
+max_y = 5
+
+[cave]
+fill = 0.5
+link = true
+
+[wfc]
+sample = ["▒..", "▒+#"]
+n = 2
+
+[graph]
+nodes = [{ name = "a" }, { name = "b", shape = "circle", tags = ["boss"] }]
+edges = [{ from = "a", to = "b", door = "locked" }]
+
+[doors]
+counts = { rectangle = { min = 1, max = 3 } }
+spacing = 5
+
+[shape_sizes.circle]
+min_w = 5
+dist = "normal"
+`
+	testYAML = `
+version: 1
+seed: 42
+mode: bsp
+room_shapes: [rectangle, circle]
+corridor_width: 2
+timeout: 1.5s
+repair: [move-door, drop-room]
+grid:
+  width: 60
+  min_y: -5
+  max_y: 5
+cave:
+  fill: 0.5
+  link: true
+wfc:
+  sample: ["▒..", "▒+#"]
+  n: 2
+graph:
+  nodes:
+    - name: a
+    - name: b
+      shape: circle
+      tags: [boss]
+  edges:
+    - {from: a, to: b, door: locked}
+doors:
+  counts:
+    rectangle: {min: 1, max: 3}
+  spacing: 5
+shape_sizes:
+  circle: {min_w: 5, dist: normal}
+`
+	testJSON = `{
+	"version": 1,
+	"seed": 42,
+	"mode": "bsp",
+	"room_shapes": ["rectangle", "circle"],
+	"corridor_width": 2,
+	"timeout": "1.5s",
+	"repair": ["move-door", "drop-room"],
+	"grid": {"width": 60, "min_y": -5, "max_y": 5},
+	"cave": {"fill": 0.5, "link": true},
+	"wfc": {"sample": ["▒..", "▒+#"], "n": 2},
+	"graph": {
+		"nodes": [{"name": "a"}, {"name": "b", "shape": "circle", "tags": ["boss"]}],
+		"edges": [{"from": "a", "to": "b", "door": "locked"}]
+	},
+	"doors": {"counts": {"rectangle": {"min": 1, "max": 3}}, "spacing": 5},
+	"shape_sizes": {"circle": {"min_w": 5, "dist": "normal"}}
+}`
+)
+
+// testWant is the testTOML config applied to a zero generator.Config.
+func testWant() generator.Config {
+	return generator.Config{
+		Mode:       generator.ModeBSP,
+		Grid:       model.Grid{MinX: -30, MaxX: 29, MinY: -5, MaxY: 5},
+		RoomShapes: []model.RoomId{model.Rectangle, model.Circle},
+		CorridorW:  2,
+		Timeout:    1500 * time.Millisecond,
+		Repair:     []generator.RepairStrategy{generator.RepairMoveDoor, generator.RepairDropRoom},
+		Cave:       generator.CaveConfig{Fill: 0.5, Link: true},
+		WFC: generator.WFCConfig{
+			Sample: [][]model.Tile{
+				{model.TileWall, model.TileRoomFloor, model.TileRoomFloor},
+				{model.TileWall, model.TileDoor, model.TileCorridor},
+			},
+			N: 2,
+		},
+		Graph: generator.RoomGraph{
+			Nodes: []generator.GraphNode{{Name: "a"}, {Name: "b", Shape: model.Circle, Tags: []string{"boss"}}},
+			Edges: []generator.GraphEdge{{From: "a", To: "b", Door: generator.DoorLocked}},
+		},
+		Doors: generator.DoorConfig{
+			Counts:  map[model.RoomId]generator.DoorRange{model.Rectangle: {Min: 1, Max: 3}},
+			Spacing: 5,
+		},
+		ShapeSizes: map[model.RoomId]generator.SizeRule{
+			model.Circle: {MinW: 5, Dist: generator.SizeNormal},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ext  string
+		data string
+	}{
+		{".toml", testTOML},
+		{".yaml", testYAML},
+		{".yml", testYAML},
+		{".json", testJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			f, err := config.Parse([]byte(tt.data), tt.ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Seed == nil || *f.Seed != 42 {
+				t.Errorf("seed = %v, want 42", f.Seed)
+			}
+			var got generator.Config
+			if err := f.Apply(&got); err != nil {
+				t.Fatal(err)
+			}
+			if want := testWant(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply gave\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyKeepsMissingFields(t *testing.T) {
+	f, err := config.Parse([]byte("version = 1\nmax_rooms = 7\n"), ".toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := generator.Config{MaxRooms: 3, CorridorW: 4, RoomShapes: []model.RoomId{model.Square}}
+	if err := f.Apply(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxRooms != 7 || cfg.CorridorW != 4 || len(cfg.RoomShapes) != 1 {
+		t.Errorf("Apply gave %+v, want only MaxRooms changed", cfg)
+	}
+}
+
+func TestFromConfigRoundTrip(t *testing.T) {
+	want := testWant()
+	b, err := json.Marshal(config.FromConfig(42, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := config.Parse(b, ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got generator.Config
+	if err := f.Apply(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip gave\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		ext   string
+		data  string
+		field string // the FieldError expected, or "" for a decode error
+		want  string
+	}{
+		{"toml no version", ".toml", "seed = 1\n", "version", "is required"},
+		{"yaml no version", ".yaml", "seed: 1\n", "version", "is required"},
+		{"toml newer version", ".toml", "version = 2\n", "version", "newer than"},
+		{"yaml newer version", ".yaml", "version: 2\n", "version", "newer than"},
+		{"toml unknown key", ".toml", "version = 1\nmax_room = 3\n", "", "max_room"},
+		{"yaml unknown key", ".yaml", "version: 1\nmax_room: 3\n", "", "max_room"},
+		{"toml nested unknown key", ".toml", "version = 1\n[cave]\nfil = 0.5\n", "", "cave.fil"},
+		{"yaml bad mode", ".yaml", "version: 1\nmode: maze\n", "", "unknown mode"},
+		{"toml bad shape", ".toml", "version = 1\nroom_shapes = [\"hexagon\"]\n", "", "hexagon"},
+		{"format", ".ini", "version = 1\n", "", "unsupported config format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Parse([]byte(tt.data), tt.ext)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse error = %v, want one containing %q", err, tt.want)
+			}
+			var fe generator.FieldError
+			if got := errors.As(err, &fe); got != (tt.field != "") || (got && fe.Field != tt.field) {
+				t.Errorf("Parse error = %#v, want a FieldError on %q", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		field string
+	}{
+		{"sample glyph", "version = 1\n[wfc]\nsample = [\"..x\"]\n", "wfc.sample[0]"},
+		{"door shape", "version = 1\n[doors]\ncounts = { hexagon = { min = 2 } }\n", "doors.counts.hexagon"},
+		{"size shape", "version = 1\n[shape_sizes.hexagon]\nmin_w = 3\n", "shape_sizes.hexagon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := config.Parse([]byte(tt.data), ".toml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cfg generator.Config
+			err = f.Apply(&cfg)
+			var fe generator.FieldError
+			if !errors.As(err, &fe) || fe.Field != tt.field {
+				t.Errorf("Apply error = %v, want a FieldError on %q", err, tt.field)
+			}
+		})
+	}
+}
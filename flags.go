@@ -0,0 +1,694 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikegio27/proc-dungeons/config"
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+	"github.com/mikegio27/proc-dungeons/tiled"
+	"github.com/mikegio27/proc-dungeons/vtt"
+)
+
+// configFlags binds every generator.Config field, plus the seed and an
+// optional config file, to a flag.FlagSet so all subcommands share the same
+// spelling.
+type configFlags struct {
+	path string
+	file *config.File
+
+	seed int64
+
+	width  int
+	height int
+	minX   int
+	maxX   int
+	minY   int
+	maxY   int
+
+	rooms  int
+	shapes string
+
+	roomMinW int
+	roomMaxW int
+	roomMinH int
+	roomMaxH int
+
+	corridorW    int
+	corridorBuff int
+
+	maxRoomArea      float64
+	maxTotalRoomArea float64
+	minRoomGap       int
+	loops            float64
+	shapeSizes       shapeSizeFlag
+	doorCounts       doorCountFlag
+	doorSpacing      int
+
+	maxIterations int
+	timeout       time.Duration
+
+	repair string
+	mode   string
+
+	caveFill      float64
+	caveBirth     int
+	caveSurvival  int
+	caveSteps     int
+	caveLink      bool
+	caveMinRegion int
+	caveRatio     float64
+
+	walkers      int
+	walkSteps    int
+	walkTurn     float64
+	walkCoverage float64
+	walkMargin   int
+	walkFloor    bool
+
+	wfcSample        string
+	wfcN             int
+	wfcSymmetry      int
+	wfcPeriodicInput bool
+	wfcPeriodic      bool
+	wfcBacktracks    int
+
+	graph string
+
+	set map[string]bool
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	cf := &configFlags{}
+	fs.StringVar(&cf.path, "config", "", "config file (.json, .toml, .yaml); flags override its values")
+	fs.Int64Var(&cf.seed, "seed", 0, "RNG seed; 0 picks one from the current time")
+
+	fs.StringVar(&cf.mode, "mode", "rooms", "generation mode: "+strings.Join(generator.ModeNames(), ", "))
+
+	fs.IntVar(&cf.width, "width", 101, "grid width in tiles, centred on the origin")
+	fs.IntVar(&cf.height, "height", 41, "grid height in tiles, centred on the origin")
+	fs.IntVar(&cf.minX, "min-x", 0, "explicit grid MinX (overrides -width)")
+	fs.IntVar(&cf.maxX, "max-x", 0, "explicit grid MaxX (overrides -width)")
+	fs.IntVar(&cf.minY, "min-y", 0, "explicit grid MinY (overrides -height)")
+	fs.IntVar(&cf.maxY, "max-y", 0, "explicit grid MaxY (overrides -height)")
+
+	fs.IntVar(&cf.rooms, "rooms", 20, "maximum number of rooms")
+	fs.StringVar(&cf.shapes, "shapes", "rectangle,circle,square,triangle", "comma-separated room shapes")
+
+	fs.IntVar(&cf.roomMinW, "room-min-w", 0, "minimum room width")
+	fs.IntVar(&cf.roomMaxW, "room-max-w", 0, "maximum room width")
+	fs.IntVar(&cf.roomMinH, "room-min-h", 0, "minimum room height")
+	fs.IntVar(&cf.roomMaxH, "room-max-h", 0, "maximum room height")
+
+	fs.IntVar(&cf.corridorW, "corridor-width", 2, "corridor thickness in tiles")
+	fs.IntVar(&cf.corridorBuff, "corridor-buffer", 1, "minimum clearance between corridors and rooms")
+
+	fs.Float64Var(&cf.maxRoomArea, "max-room-area", 0, "max fraction of the grid one room may cover (0 = 0.05)")
+	fs.Float64Var(&cf.maxTotalRoomArea, "max-total-room-area", 0, "max fraction of the grid all rooms may cover (0 = 0.75)")
+	fs.IntVar(&cf.minRoomGap, "min-room-gap", 0, "minimum tiles between rooms (0 = 4)")
+	fs.Float64Var(&cf.loops, "loops", 0, "mst mode: fraction of the other Delaunay edges added back as loops")
+	fs.IntVar(&cf.maxIterations, "max-iterations", 0, "abort after this many generation steps (0 = no limit)")
+	fs.DurationVar(&cf.timeout, "timeout", 0, "abort generation after this long (0 = no limit)")
+	fs.StringVar(&cf.repair, "repair", "relax-buffer,move-door,drop-room", "comma-separated repair strategies for unreachable rooms, tried in order, or none")
+	fs.Float64Var(&cf.caveFill, "cave-fill", 0, "cave mode: chance a cell starts as rock (0 = 0.45)")
+	fs.IntVar(&cf.caveBirth, "cave-birth", 0, "cave mode: rock neighbours that fill an open cell (0 = 5)")
+	fs.IntVar(&cf.caveSurvival, "cave-survival", 0, "cave mode: rock neighbours that keep a rock cell (0 = 4)")
+	fs.IntVar(&cf.caveSteps, "cave-steps", 0, "cave mode: smoothing iterations (0 = 4)")
+	fs.BoolVar(&cf.caveLink, "cave-link", false, "cave mode: join every large region with corridors instead of keeping the largest")
+	fs.IntVar(&cf.caveMinRegion, "cave-min-region", 0, "cave mode: smallest region -cave-link keeps, in tiles (0 = 16)")
+	fs.IntVar(&cf.walkers, "walkers", 0, "walk mode: number of walkers (0 = 1)")
+	fs.IntVar(&cf.walkSteps, "walk-steps", 0, "walk mode: steps per walker (0 = grid area)")
+	fs.Float64Var(&cf.walkTurn, "walk-turn", 0, "walk mode: chance of a new direction each step (0 = 0.2)")
+	fs.Float64Var(&cf.walkCoverage, "walk-coverage", 0, "walk mode: fraction of the grid to carve (0 = 0.3)")
+	fs.IntVar(&cf.walkMargin, "walk-margin", 0, "walk mode: tiles walkers keep from the grid edge (0 = 2)")
+	fs.BoolVar(&cf.walkFloor, "walk-floor", false, "walk mode: carve room floor with walls instead of corridor")
+	fs.StringVar(&cf.wfcSample, "wfc-sample", "", "wfc mode: file holding the sample map, as printed by generate")
+	fs.IntVar(&cf.wfcN, "wfc-n", 0, "wfc mode: pattern size in tiles (0 = 3)")
+	fs.IntVar(&cf.wfcSymmetry, "wfc-symmetry", 0, "wfc mode: rotations and reflections of each pattern to use, 1-8 (0 = 8)")
+	fs.BoolVar(&cf.wfcPeriodicInput, "wfc-periodic-input", false, "wfc mode: read patterns across the sample edges as if it wrapped")
+	fs.BoolVar(&cf.wfcPeriodic, "wfc-periodic", false, "wfc mode: make the dungeon tile seamlessly")
+	fs.IntVar(&cf.wfcBacktracks, "wfc-backtracks", 0, "wfc mode: choices to undo after contradictions before giving up (0 = 1000)")
+	fs.StringVar(&cf.graph, "graph", "", "graph mode: room graph file (.json, .toml, .yaml) with nodes and edges")
+	fs.Float64Var(&cf.caveRatio, "cave-ratio", 0, "hybrid mode: share of open floor left as cave, the rest built rooms (0 = 0.7)")
+	fs.Var(&cf.doorCounts, "doors", "per-shape door count `shape=n` or shape=min-max; repeatable")
+	fs.IntVar(&cf.doorSpacing, "door-spacing", 0, "minimum tiles between doors of one room (0 = 4)")
+	fs.Var(&cf.shapeSizes, "shape-size", "per-shape size rule `shape=W[xH][:dist]`, W and H as n or min-max, dist uniform|normal; repeatable")
+	return cf
+}
+
+// collect records which flags were given explicitly. It must be called
+// after fs.Parse.
+func (cf *configFlags) collect(fs *flag.FlagSet) {
+	cf.set = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { cf.set[f.Name] = true })
+}
+
+// Seed returns the requested seed: the -seed flag, then the config file,
+// then one derived from the current time.
+func (cf *configFlags) Seed() int64 {
+	switch {
+	case cf.set["seed"] && cf.seed != 0:
+		return cf.seed
+	case cf.file != nil && cf.file.Seed != nil && *cf.file.Seed != 0:
+		return *cf.file.Seed
+	}
+	return time.Now().UnixNano()
+}
+
+// Config builds a generator.Config from the flag defaults, then the config
+// file named by -config, then any flags given explicitly.
+func (cf *configFlags) Config() (generator.Config, error) {
+	var cfg generator.Config
+	if err := cf.apply(&cfg, true); err != nil {
+		return generator.Config{}, err
+	}
+	if cf.path != "" {
+		f, err := config.Load(cf.path)
+		if err != nil {
+			return generator.Config{}, err
+		}
+		cf.file = &f
+		if err := f.Apply(&cfg); err != nil {
+			return generator.Config{}, err
+		}
+		if err := cf.apply(&cfg, false); err != nil {
+			return generator.Config{}, err
+		}
+	}
+	if err := cf.load(&cfg); err != nil {
+		return generator.Config{}, err
+	}
+	return cfg, nil
+}
+
+// load reads the files named by -wfc-sample and -graph into cfg. It runs
+// once, after the config file is merged, so each file is read a single
+// time; the flags default to empty, so a path is only set when given and
+// then wins over the config file.
+func (cf *configFlags) load(cfg *generator.Config) error {
+	if cf.wfcSample != "" {
+		sample, err := readSample(cf.wfcSample)
+		if err != nil {
+			return err
+		}
+		cfg.WFC.Sample = sample
+	}
+	if cf.graph != "" {
+		graph, err := config.LoadGraph(cf.graph)
+		if err != nil {
+			return err
+		}
+		cfg.Graph = graph
+	}
+	return nil
+}
+
+// apply copies flag values into cfg. With all unset only the flags given
+// on the command line are copied.
+func (cf *configFlags) apply(cfg *generator.Config, all bool) error {
+	use := func(name string) bool { return all || cf.set[name] }
+
+	if use("mode") {
+		if err := cfg.Mode.UnmarshalText([]byte(cf.mode)); err != nil {
+			return err
+		}
+	}
+	if use("width") {
+		cfg.Grid.MinX, cfg.Grid.MaxX = config.CenteredSpan(int32(cf.width))
+	}
+	if use("height") {
+		cfg.Grid.MinY, cfg.Grid.MaxY = config.CenteredSpan(int32(cf.height))
+	}
+	// Explicit bounds override -width and -height, but only when given.
+	if cf.set["min-x"] {
+		cfg.Grid.MinX = int32(cf.minX)
+	}
+	if cf.set["max-x"] {
+		cfg.Grid.MaxX = int32(cf.maxX)
+	}
+	if cf.set["min-y"] {
+		cfg.Grid.MinY = int32(cf.minY)
+	}
+	if cf.set["max-y"] {
+		cfg.Grid.MaxY = int32(cf.maxY)
+	}
+
+	if use("rooms") {
+		cfg.MaxRooms = cf.rooms
+	}
+	if use("shapes") {
+		shapes, err := parseShapes(cf.shapes)
+		if err != nil {
+			return err
+		}
+		cfg.RoomShapes = shapes
+	}
+	if use("room-min-w") {
+		cfg.RoomMinW = int32(cf.roomMinW)
+	}
+	if use("room-max-w") {
+		cfg.RoomMaxW = int32(cf.roomMaxW)
+	}
+	if use("room-min-h") {
+		cfg.RoomMinH = int32(cf.roomMinH)
+	}
+	if use("room-max-h") {
+		cfg.RoomMaxH = int32(cf.roomMaxH)
+	}
+	if use("corridor-width") {
+		cfg.CorridorW = int32(cf.corridorW)
+	}
+	if use("corridor-buffer") {
+		cfg.CorridorBuff = int32(cf.corridorBuff)
+	}
+	if use("max-room-area") {
+		cfg.MaxRoomAreaFraction = cf.maxRoomArea
+	}
+	if use("max-total-room-area") {
+		cfg.MaxTotalRoomAreaFraction = cf.maxTotalRoomArea
+	}
+	if use("min-room-gap") {
+		cfg.MinRoomGap = int32(cf.minRoomGap)
+	}
+	if use("loops") {
+		cfg.Loops = cf.loops
+	}
+	if use("max-iterations") {
+		cfg.MaxIterations = cf.maxIterations
+	}
+	if use("timeout") {
+		cfg.Timeout = cf.timeout
+	}
+	if use("repair") {
+		repair, err := parseRepair(cf.repair)
+		if err != nil {
+			return err
+		}
+		cfg.Repair = repair
+	}
+	if use("cave-fill") {
+		cfg.Cave.Fill = cf.caveFill
+	}
+	if use("cave-birth") {
+		cfg.Cave.Birth = cf.caveBirth
+	}
+	if use("cave-survival") {
+		cfg.Cave.Survival = cf.caveSurvival
+	}
+	if use("cave-steps") {
+		cfg.Cave.Steps = cf.caveSteps
+	}
+	if use("cave-link") {
+		cfg.Cave.Link = cf.caveLink
+	}
+	if use("cave-min-region") {
+		cfg.Cave.MinRegion = cf.caveMinRegion
+	}
+	if use("cave-ratio") {
+		cfg.Cave.Ratio = cf.caveRatio
+	}
+	if use("walkers") {
+		cfg.Walk.Walkers = cf.walkers
+	}
+	if use("walk-steps") {
+		cfg.Walk.Steps = cf.walkSteps
+	}
+	if use("walk-turn") {
+		cfg.Walk.Turn = cf.walkTurn
+	}
+	if use("walk-coverage") {
+		cfg.Walk.Coverage = cf.walkCoverage
+	}
+	if use("walk-margin") {
+		cfg.Walk.EdgeMargin = int32(cf.walkMargin)
+	}
+	if use("walk-floor") {
+		cfg.Walk.Floor = cf.walkFloor
+	}
+	if use("wfc-n") {
+		cfg.WFC.N = cf.wfcN
+	}
+	if use("wfc-symmetry") {
+		cfg.WFC.Symmetry = cf.wfcSymmetry
+	}
+	if use("wfc-periodic-input") {
+		cfg.WFC.PeriodicInput = cf.wfcPeriodicInput
+	}
+	if use("wfc-periodic") {
+		cfg.WFC.Periodic = cf.wfcPeriodic
+	}
+	if use("wfc-backtracks") {
+		cfg.WFC.Backtracks = cf.wfcBacktracks
+	}
+	if use("door-spacing") {
+		cfg.Doors.Spacing = int32(cf.doorSpacing)
+	}
+	for shape, r := range cf.doorCounts {
+		if cfg.Doors.Counts == nil {
+			cfg.Doors.Counts = make(map[model.RoomId]generator.DoorRange)
+		}
+		cfg.Doors.Counts[shape] = r
+	}
+	for shape, rule := range cf.shapeSizes {
+		if cfg.ShapeSizes == nil {
+			cfg.ShapeSizes = make(map[model.RoomId]generator.SizeRule)
+		}
+		cfg.ShapeSizes[shape] = rule
+	}
+	return nil
+}
+
+// renderFlags binds render.Options to a flag.FlagSet for the commands that
+// draw the map.
+type renderFlags struct {
+	glyphs  string
+	start   string
+	narrow  bool
+	border  bool
+	padding int
+
+	color      string
+	theme      string
+	tileColors string
+}
+
+func addRenderFlags(fs *flag.FlagSet) *renderFlags {
+	rf := &renderFlags{}
+	fs.StringVar(&rf.glyphs, "glyphs", "", "glyph overrides `tile=glyph,...`, tiles Empty|RoomFloor|Corridor|Door|Wall")
+	fs.StringVar(&rf.start, "start-glyph", "*", "glyph for corridor starts")
+	fs.BoolVar(&rf.narrow, "narrow", false, "one column per tile instead of two")
+	fs.BoolVar(&rf.border, "border", true, "draw a wall border around the grid")
+	fs.IntVar(&rf.padding, "padding", 0, "blank tiles around the map")
+	fs.StringVar(&rf.color, "color", "auto", "ANSI colors: auto, none, 16, 256 or truecolor")
+	fs.StringVar(&rf.theme, "theme", "default", "color theme: "+strings.Join(render.ThemeNames(), ", "))
+	fs.StringVar(&rf.tileColors, "tile-colors", "", "theme overrides `tile=#rrggbb,...`, tiles as for -glyphs plus Start")
+	return rf
+}
+
+// Options returns the render.Options selected by the flags. With -color
+// auto the mode is picked for out.
+func (rf *renderFlags) Options(out io.Writer) (render.Options, error) {
+	glyphs, err := render.ParseGlyphs(rf.glyphs)
+	if err != nil {
+		return render.Options{}, err
+	}
+	start := []rune(rf.start)
+	if len(start) != 1 {
+		return render.Options{}, fmt.Errorf("-start-glyph must be one character, got %q", rf.start)
+	}
+	if rf.padding < 0 {
+		return render.Options{}, fmt.Errorf("-padding must not be negative, got %d", rf.padding)
+	}
+
+	mode := render.DetectColor(out)
+	if rf.color != "auto" {
+		var ok bool
+		if mode, ok = render.ParseColorMode(rf.color); !ok {
+			return render.Options{}, fmt.Errorf("unknown -color %q", rf.color)
+		}
+	}
+	theme, ok := render.Themes[rf.theme]
+	if !ok {
+		return render.Options{}, fmt.Errorf("unknown -theme %q", rf.theme)
+	}
+	if err := render.ParseTileColors(rf.tileColors, &theme); err != nil {
+		return render.Options{}, err
+	}
+
+	return render.Options{
+		Glyphs:  glyphs,
+		Start:   start[0],
+		Wide:    !rf.narrow,
+		Border:  rf.border,
+		Padding: rf.padding,
+		Color:   mode,
+		Theme:   &theme,
+	}, nil
+}
+
+// imageFlags binds render.ImageOptions for the image output formats.
+type imageFlags struct {
+	scale     int
+	gridLines bool
+	outline   string
+	noStarts  bool
+	vttGrid   int
+	delay     time.Duration
+}
+
+func addImageFlags(fs *flag.FlagSet) *imageFlags {
+	imf := &imageFlags{}
+	fs.IntVar(&imf.scale, "scale", 8, "image pixels per tile")
+	fs.BoolVar(&imf.gridLines, "grid-lines", false, "draw lines between tiles in images")
+	fs.StringVar(&imf.outline, "outline", "shape", "room outlines in images: none, box or shape")
+	fs.BoolVar(&imf.noStarts, "no-start-markers", false, "leave corridor starts unmarked in images")
+	fs.DurationVar(&imf.delay, "frame-delay", 100*time.Millisecond, "time each GIF frame is shown")
+	fs.IntVar(&imf.vttGrid, "vtt-grid", vtt.DefaultOptions.PixelsPerGrid, "Universal VTT image pixels per tile")
+	return imf
+}
+
+// ImageOptions returns the render.ImageOptions selected by the flags,
+// coloring tiles with the theme chosen by the render flags.
+func (imf *imageFlags) ImageOptions(theme *render.Theme) (render.ImageOptions, error) {
+	if imf.scale < 1 {
+		return render.ImageOptions{}, fmt.Errorf("-scale must be at least 1, got %d", imf.scale)
+	}
+	outline, ok := render.ParseOutline(imf.outline)
+	if !ok {
+		return render.ImageOptions{}, fmt.Errorf("unknown -outline %q", imf.outline)
+	}
+	opts := render.DefaultImageOptions
+	opts.Scale = imf.scale
+	opts.Theme = theme
+	opts.Grid = imf.gridLines
+	opts.Outline = outline
+	opts.Starts = !imf.noStarts
+	return opts, nil
+}
+
+// SVGOptions returns the render.SVGOptions selected by the flags. SVG
+// output uses -scale and the start markers setting; rooms are always
+// labelled.
+func (imf *imageFlags) SVGOptions(theme *render.Theme) render.SVGOptions {
+	opts := render.DefaultSVGOptions
+	opts.Scale = imf.scale * 2
+	opts.Theme = theme
+	opts.Starts = !imf.noStarts
+	return opts
+}
+
+// Animation returns an empty render.Animation drawn with img, the image
+// options from the same flags. Rooms are outlined by their bounding box
+// instead of their shape, since their floors are only drawn at the end of
+// generation.
+func (imf *imageFlags) Animation(img render.ImageOptions) (*render.Animation, error) {
+	if imf.delay < 10*time.Millisecond {
+		return nil, fmt.Errorf("-frame-delay must be at least 10ms, got %v", imf.delay)
+	}
+	if img.Outline == render.OutlineShape {
+		img.Outline = render.OutlineBox
+	}
+	return render.NewAnimation(img, imf.delay), nil
+}
+
+// VTTOptions returns the vtt.Options selected by the flags. The embedded
+// image is drawn with img, the image options from the same flags, at
+// -vtt-grid pixels per tile instead of -scale.
+func (imf *imageFlags) VTTOptions(img render.ImageOptions) (vtt.Options, error) {
+	if imf.vttGrid < 1 {
+		return vtt.Options{}, fmt.Errorf("-vtt-grid must be at least 1, got %d", imf.vttGrid)
+	}
+	return vtt.Options{PixelsPerGrid: imf.vttGrid, Image: img}, nil
+}
+
+// tiledFlags binds tiled.Options for the Tiled output formats.
+type tiledFlags struct {
+	tileset  string
+	gids     string
+	tileSize int
+}
+
+func addTiledFlags(fs *flag.FlagSet) *tiledFlags {
+	tf := &tiledFlags{}
+	fs.StringVar(&tf.tileset, "tileset", "dungeon.tsx", "external Tiled tileset the map refers to")
+	fs.StringVar(&tf.gids, "gids", "", "Tiled GID overrides `tile=gid,...`, tiles as for -glyphs")
+	fs.IntVar(&tf.tileSize, "tile-size", 32, "Tiled tile size in pixels")
+	return tf
+}
+
+// Options returns the tiled.Options selected by the flags.
+func (tf *tiledFlags) Options() (tiled.Options, error) {
+	if tf.tileSize < 1 {
+		return tiled.Options{}, fmt.Errorf("-tile-size must be at least 1, got %d", tf.tileSize)
+	}
+	gids, err := tiled.ParseGIDs(tf.gids, tiled.DefaultOptions.GIDs)
+	if err != nil {
+		return tiled.Options{}, err
+	}
+	opts := tiled.DefaultOptions
+	opts.TileWidth, opts.TileHeight = tf.tileSize, tf.tileSize
+	opts.Tileset.Source = tf.tileset
+	opts.GIDs = gids
+	return opts, nil
+}
+
+func parseShapes(s string) ([]model.RoomId, error) {
+	var shapes []model.RoomId
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := model.ParseRoomId(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown room shape %q", name)
+		}
+		shapes = append(shapes, id)
+	}
+	return shapes, nil
+}
+
+func parseRepair(s string) ([]generator.RepairStrategy, error) {
+	repair := []generator.RepairStrategy{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var r generator.RepairStrategy
+		if err := r.UnmarshalText([]byte(name)); err != nil {
+			return nil, err
+		}
+		repair = append(repair, r)
+	}
+	return repair, nil
+}
+
+// readSample reads the -wfc-sample map from path.
+func readSample(path string) ([][]model.Tile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sample, err := render.ParseASCII(f, render.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sample, nil
+}
+
+// shapeSizeFlag collects repeated -shape-size values such as
+// "rectangle=4-12x3-6:normal" or "circle=5".
+type shapeSizeFlag map[model.RoomId]generator.SizeRule
+
+func (f *shapeSizeFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var parts []string
+	for shape, r := range *f {
+		parts = append(parts, fmt.Sprintf("%s=%d-%dx%d-%d:%s", shape, r.MinW, r.MaxW, r.MinH, r.MaxH, r.Dist))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *shapeSizeFlag) Set(v string) error {
+	name, spec, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("want shape=W[xH][:dist], got %q", v)
+	}
+	shape, ok := model.ParseRoomId(strings.TrimSpace(name))
+	if !ok {
+		return fmt.Errorf("unknown room shape %q", name)
+	}
+
+	var rule generator.SizeRule
+	spec, dist, hasDist := strings.Cut(spec, ":")
+	if hasDist {
+		if err := rule.Dist.UnmarshalText([]byte(dist)); err != nil {
+			return err
+		}
+		if rule.Dist == generator.SizeBuckets {
+			return errors.New("the buckets distribution can only be set in a config file")
+		}
+	}
+
+	ws, hs, hasH := strings.Cut(spec, "x")
+	var err error
+	if rule.MinW, rule.MaxW, err = parseSpan(ws); err != nil {
+		return err
+	}
+	rule.MinH, rule.MaxH = rule.MinW, rule.MaxW
+	if hasH {
+		if rule.MinH, rule.MaxH, err = parseSpan(hs); err != nil {
+			return err
+		}
+	}
+
+	if *f == nil {
+		*f = make(shapeSizeFlag)
+	}
+	(*f)[shape] = rule
+	return nil
+}
+
+// doorCountFlag collects repeated -doors values such as "rectangle=1-3"
+// or "circle=2".
+type doorCountFlag map[model.RoomId]generator.DoorRange
+
+func (f *doorCountFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var parts []string
+	for shape, r := range *f {
+		parts = append(parts, fmt.Sprintf("%s=%d-%d", shape, r.Min, r.Max))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *doorCountFlag) Set(v string) error {
+	name, spec, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("want shape=n or shape=min-max, got %q", v)
+	}
+	shape, ok := model.ParseRoomId(strings.TrimSpace(name))
+	if !ok {
+		return fmt.Errorf("unknown room shape %q", name)
+	}
+	lo, hi, err := parseSpan(spec)
+	if err != nil {
+		return err
+	}
+	if *f == nil {
+		*f = make(doorCountFlag)
+	}
+	(*f)[shape] = generator.DoorRange{Min: lo, Max: hi}
+	return nil
+}
+
+// parseSpan parses "n" or "min-max".
+func parseSpan(s string) (lo, hi int32, err error) {
+	los, his, isRange := strings.Cut(s, "-")
+	l, err := strconv.ParseInt(strings.TrimSpace(los), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad size %q", s)
+	}
+	if !isRange {
+		return int32(l), int32(l), nil
+	}
+	h, err := strconv.ParseInt(strings.TrimSpace(his), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad size %q", s)
+	}
+	return int32(l), int32(h), nil
+}
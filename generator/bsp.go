@@ -0,0 +1,231 @@
+package generator
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// bspNode is one partition of the grid. Leaves hold one room each; the
+// children of a split are in left-to-right (or bottom-to-top) order.
+type bspNode struct {
+	lo, hi      model.Cell
+	left, right *bspNode
+	room        int
+}
+
+func (n *bspNode) width() int32  { return n.hi.X - n.lo.X + 1 }
+func (n *bspNode) height() int32 { return n.hi.Y - n.lo.Y + 1 }
+func (n *bspNode) area() int32   { return n.width() * n.height() }
+
+// canSplit reports whether either side of n is long enough for two leaves
+// of at least minLeaf tiles.
+func (n *bspNode) canSplit(minLeaf int32) bool {
+	return n.width() >= 2*minLeaf || n.height() >= 2*minLeaf
+}
+
+// split cuts n across its longer side, or the other side when the longer
+// one is too short, somewhere in the middle 40% where both halves fit.
+func (n *bspNode) split(s *State, minLeaf int32) {
+	vertical := n.width() >= n.height()
+	if vertical && n.width() < 2*minLeaf {
+		vertical = false
+	} else if !vertical && n.height() < 2*minLeaf {
+		vertical = true
+	}
+
+	size := n.height()
+	if vertical {
+		size = n.width()
+	}
+	lo := max(minLeaf, size*3/10)
+	hi := min(size-minLeaf, size*7/10)
+	if hi < lo {
+		lo, hi = minLeaf, size-minLeaf
+	}
+	cut := lo + s.Rand.Int31n(hi-lo+1)
+
+	left, right := *n, *n
+	if vertical {
+		left.hi.X = n.lo.X + cut - 1
+		right.lo.X = n.lo.X + cut
+	} else {
+		left.hi.Y = n.lo.Y + cut - 1
+		right.lo.Y = n.lo.Y + cut
+	}
+	n.left, n.right = &left, &right
+}
+
+// BSPPlacer splits the grid by binary space partitioning until there is
+// one leaf per room, always splitting the largest leaf that still fits two
+// rooms, and places one room of a random configured shape in each leaf.
+// Rooms are kept half of Config.MinRoomGap inside their leaf, so rooms in
+// neighbouring leaves are at least the gap apart. The total area cap does
+// not apply; MaxRooms rooms are placed whenever the grid has space for
+// that many leaves.
+//
+// Rooms are stored in leaf order, so rooms that are close in d.Rooms are
+// close on the map. BSPConnector uses the partition tree to join them.
+type BSPPlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (BSPPlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	if len(s.Config.RoomShapes) == 0 {
+		return ErrNoShapes
+	}
+	s.tree = nil
+	if s.Config.MaxRooms <= 0 {
+		return nil
+	}
+
+	inset := (s.g.minRoomGap() + 1) / 2
+	minLeaf := minRoomSide + 2*inset
+	grid := s.Config.Grid
+	root := &bspNode{lo: model.Cell{X: grid.MinX, Y: grid.MinY}, hi: model.Cell{X: grid.MaxX, Y: grid.MaxY}}
+	if root.width() < minLeaf || root.height() < minLeaf {
+		return ErrNoRoomsPlaced
+	}
+
+	leaves := []*bspNode{root}
+	for len(leaves) < s.Config.MaxRooms {
+		if err := s.Tick(); err != nil {
+			return err
+		}
+		best := -1
+		for i, l := range leaves {
+			if l.canSplit(minLeaf) && (best < 0 || l.area() > leaves[best].area()) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		l := leaves[best]
+		l.split(s, minLeaf)
+		leaves = slices.Replace(leaves, best, best+1, l.left, l.right)
+	}
+
+	d.Rooms = make([]model.Room, 0, len(leaves))
+	for _, l := range leaves {
+		if err := s.Tick(); err != nil {
+			return err
+		}
+		room := s.g.leafRoom(l, inset)
+		l.room = len(d.Rooms)
+		d.Rooms = append(d.Rooms, room)
+		s.Emit(RoomPlaced{Room: l.room, Placed: room})
+		s.Record(StepRoomPlaced, d)
+	}
+	s.tree = root
+	return nil
+}
+
+// leafRoom returns a room of a random configured shape placed at random
+// inside leaf l shrunk by inset on every side. Its size is drawn as for
+// RandomRoom and then clamped to that space.
+func (g *Generator) leafRoom(l *bspNode, inset int32) model.Room {
+	shape := g.cfg.RoomShapes[g.rng.Intn(len(g.cfg.RoomShapes))]
+	spaceW, spaceH := l.width()-2*inset, l.height()-2*inset
+
+	w, h := g.roomDimensions(shape)
+	w, h = min(max(w, minRoomSide), spaceW), min(max(h, minRoomSide), spaceH)
+	if shape == model.Circle || shape == model.Square {
+		w = min(w, h)
+		h = w
+	}
+
+	x := l.lo.X + inset + g.rng.Int31n(spaceW-w+1)
+	y := l.lo.Y + inset + g.rng.Int31n(spaceH-h+1)
+	return model.Room{
+		Shape:       shape,
+		TopLeft:     model.Cell{X: x, Y: y},
+		BottomRight: model.Cell{X: x + w - 1, Y: y + h - 1},
+	}
+}
+
+// BSPConnector joins the rooms placed by BSPPlacer along the partition
+// tree. The first room is reached from the grid edge, as in GenPaths; then,
+// bottom up, every split is bridged by one corridor from the doors and
+// corridors on one side to the nearest door on the other. Unreachable
+// rooms are repaired as by CorridorConnector. Without a partition tree,
+// for instance after another placer, it behaves as CorridorConnector.
+type BSPConnector struct{}
+
+// Connect implements Connector.
+func (BSPConnector) Connect(s *State, d *model.Dungeon) error {
+	if s.tree == nil {
+		return CorridorConnector{}.Connect(s, d)
+	}
+	g := s.g
+	p := g.planPaths(d, d.Rooms)
+
+	for i := range d.Rooms {
+		if !p.hasDoor[i] {
+			continue
+		}
+		if _, err := g.connectRoom(d, p, i); err != nil && !errors.Is(err, ErrUnreachableRoom) {
+			return err
+		}
+		break
+	}
+	if _, _, err := g.connectSiblings(d, p, s.tree); err != nil {
+		return err
+	}
+
+	var err error
+	g.report, err = g.ensureConnected(d, p)
+	return err
+}
+
+// connectSiblings connects the two halves of every split below n, bottom
+// up, and returns the walkable cells (doors and corridors) of n and the
+// rooms in it that have a door.
+func (g *Generator) connectSiblings(d *model.Dungeon, p *pathPlan, n *bspNode) ([]model.Cell, []int, error) {
+	if n.left == nil {
+		if !p.hasDoor[n.room] {
+			return nil, nil, nil
+		}
+		return []model.Cell{p.doors[n.room]}, []int{n.room}, nil
+	}
+
+	lCells, lRooms, err := g.connectSiblings(d, p, n.left)
+	if err != nil {
+		return nil, nil, err
+	}
+	rCells, rRooms, err := g.connectSiblings(d, p, n.right)
+	if err != nil {
+		return nil, nil, err
+	}
+	cells := append(lCells, rCells...)
+	rooms := append(lRooms, rRooms...)
+	if len(lCells) == 0 || len(rRooms) == 0 {
+		return cells, rooms, nil
+	}
+
+	// Aim for the door on the right that is closest to the left half.
+	centre := model.Cell{X: (n.left.lo.X + n.left.hi.X) / 2, Y: (n.left.lo.Y + n.left.hi.Y) / 2}
+	target := rRooms[0]
+	for _, j := range rRooms[1:] {
+		if manhattan(p.doors[j], centre) < manhattan(p.doors[target], centre) {
+			target = j
+		}
+	}
+
+	path, src, err := g.findPathFrom(lCells, p.doors[target], p.blocked)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == nil {
+		g.emit(PathFailed{Room: target, Door: p.doors[target]})
+		return cells, rooms, nil
+	}
+	g.emit(PathFound{Room: target, From: src, Door: p.doors[target], Length: len(path)})
+	g.carvePath(d, p, target, path, p.blocked)
+	return append(cells, path...), rooms, nil
+}
+
+func manhattan(a, b model.Cell) int32 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return max(dx, -dx) + max(dy, -dy)
+}
@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ctxCheckInterval is how many iterations pass between context checks.
+// Checking on every step would dominate the cost of a BFS expansion.
+const ctxCheckInterval = 256
+
+// budget bounds one Generate call by context, iteration count and time.
+type budget struct {
+	ctx      context.Context
+	maxIters int
+	timeout  time.Duration
+	deadline time.Time
+	iters    int
+}
+
+func newBudget(ctx context.Context, cfg Config) *budget {
+	b := &budget{ctx: ctx, maxIters: cfg.MaxIterations, timeout: cfg.Timeout}
+	if cfg.Timeout > 0 {
+		b.deadline = time.Now().Add(cfg.Timeout)
+	}
+	return b
+}
+
+// tick counts one unit of work: a room placement attempt, a perimeter
+// probe or a BFS expansion. It returns the context error once the context
+// is done, or ErrBudgetExceeded once the iteration or time budget runs out.
+func (b *budget) tick() error {
+	if b == nil {
+		return nil
+	}
+	b.iters++
+	if b.maxIters > 0 && b.iters > b.maxIters {
+		return fmt.Errorf("%w: more than %d iterations", ErrBudgetExceeded, b.maxIters)
+	}
+	if b.iters%ctxCheckInterval != 0 {
+		return nil
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: ran past the %v timeout", ErrBudgetExceeded, b.timeout)
+	}
+	return nil
+}
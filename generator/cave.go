@@ -0,0 +1,375 @@
+package generator
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Defaults for the zero fields of CaveConfig.
+const (
+	defaultCaveFill      = 0.45
+	defaultCaveBirth     = 5
+	defaultCaveSurvival  = 4
+	defaultCaveSteps     = 4
+	defaultCaveMinRegion = 16
+)
+
+// CaveConfig controls the cellular automaton of ModeCave. Zero fields
+// select the defaults, which give open, well connected caverns.
+type CaveConfig struct {
+	// Fill is the chance that a cell starts as rock; 0 selects 0.45.
+	Fill float64
+	// Birth is how many of its 8 neighbours must be rock for an open
+	// cell to fill in; 0 selects 5.
+	Birth int
+	// Survival is how many of its 8 neighbours must be rock for a rock
+	// cell to stay; 0 selects 4.
+	Survival int
+	// Steps is the number of smoothing iterations; 0 selects 4.
+	Steps int
+	// Link keeps every region of at least MinRegion cells and joins them
+	// to the largest with corridors. Without it only the largest region
+	// is kept.
+	Link bool
+	// MinRegion is the smallest region Link keeps; 0 selects 16.
+	MinRegion int
+	// Ratio is the share of open floor ModeHybrid leaves as cave, the rest
+	// being built rooms; 0 selects 0.7 and 1 builds no rooms.
+	Ratio float64
+}
+
+func (c CaveConfig) fill() float64 {
+	if c.Fill > 0 {
+		return c.Fill
+	}
+	return defaultCaveFill
+}
+
+func (c CaveConfig) birth() int {
+	if c.Birth > 0 {
+		return c.Birth
+	}
+	return defaultCaveBirth
+}
+
+func (c CaveConfig) survival() int {
+	if c.Survival > 0 {
+		return c.Survival
+	}
+	return defaultCaveSurvival
+}
+
+func (c CaveConfig) steps() int {
+	if c.Steps > 0 {
+		return c.Steps
+	}
+	return defaultCaveSteps
+}
+
+func (c CaveConfig) minRegion() int {
+	if c.MinRegion > 0 {
+		return c.MinRegion
+	}
+	return defaultCaveMinRegion
+}
+
+// CavePipeline grows caves with CavePlacer, joins them with CaveConnector
+// and walls them in with CaveWalls.
+func CavePipeline() Pipeline {
+	return NewPipeline(CavePlacer{}, CaveConnector{}, CaveWalls{})
+}
+
+// CavePlacer fills the grid with random rock, smooths it with
+// Config.Cave.Steps rounds of the birth and survival rules, and keeps the
+// largest open region, or with Config.Cave.Link every region big enough.
+// The grid edge is always rock. Each kept region becomes a model.Cave room
+// over its bounding box, largest first, and its cells become room floor.
+// It fails with ErrNoRoomsPlaced when no open cell is left.
+type CavePlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (CavePlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	regions, err := s.g.caveRegions(d)
+	if err != nil {
+		return err
+	}
+	if len(regions) == 0 {
+		return ErrNoRoomsPlaced
+	}
+	s.caves = regions
+	return nil
+}
+
+// caveRegions runs the automaton and returns the regions kept, largest
+// first, after writing them to d as floor and Cave rooms.
+func (g *Generator) caveRegions(d *model.Dungeon) ([][]model.Cell, error) {
+	cfg := g.cfg.Cave
+	grid := g.cfg.Grid
+	rock := make([]bool, len(d.Tiles))
+	at := func(c model.Cell) bool {
+		i, ok := grid.Index(c)
+		return !ok || rock[i]
+	}
+
+	// show writes the open cells to d as floor, for recorded frames.
+	show := func(step Step) {
+		if g.recorder == nil {
+			return
+		}
+		for i := range d.Tiles {
+			d.Tiles[i] = model.TileEmpty
+			if !rock[i] {
+				d.Tiles[i] = model.TileRoomFloor
+			}
+		}
+		g.record(step, d)
+	}
+
+	g.eachCell(func(c model.Cell) {
+		i, _ := grid.Index(c)
+		rock[i] = grid.OnGridBoundary(c) || g.rng.Float64() < cfg.fill()
+	})
+	show(StepCaveSmoothed)
+
+	next := make([]bool, len(rock))
+	for range cfg.steps() {
+		for y := grid.MinY; y <= grid.MaxY; y++ {
+			if err := g.budget.tick(); err != nil {
+				return nil, err
+			}
+			for x := grid.MinX; x <= grid.MaxX; x++ {
+				c := model.Cell{X: x, Y: y}
+				n := 0
+				for dy := int32(-1); dy <= 1; dy++ {
+					for dx := int32(-1); dx <= 1; dx++ {
+						if (dx != 0 || dy != 0) && at(model.Cell{X: x + dx, Y: y + dy}) {
+							n++
+						}
+					}
+				}
+				i, _ := grid.Index(c)
+				if rock[i] {
+					next[i] = n >= cfg.survival()
+				} else {
+					next[i] = n >= cfg.birth()
+				}
+				if grid.OnGridBoundary(c) {
+					next[i] = true
+				}
+			}
+		}
+		rock, next = next, rock
+		show(StepCaveSmoothed)
+	}
+
+	// Flood fill the open cells into 4-connected regions, in scan order
+	// so the result does not depend on map iteration.
+	seen := make([]bool, len(rock))
+	var regions [][]model.Cell
+	g.eachCell(func(c model.Cell) {
+		i, _ := grid.Index(c)
+		if rock[i] || seen[i] {
+			return
+		}
+		seen[i] = true
+		region := []model.Cell{c}
+		for k := 0; k < len(region); k++ {
+			for _, di := range dirs4 {
+				n := model.Cell{X: region[k].X + di.X, Y: region[k].Y + di.Y}
+				j, ok := grid.Index(n)
+				if ok && !rock[j] && !seen[j] {
+					seen[j] = true
+					region = append(region, n)
+				}
+			}
+		}
+		regions = append(regions, region)
+	})
+	slices.SortStableFunc(regions, func(a, b []model.Cell) int { return cmp.Compare(len(b), len(a)) })
+
+	keep := min(len(regions), 1)
+	if cfg.Link {
+		for keep < len(regions) && len(regions[keep]) >= cfg.minRegion() {
+			keep++
+		}
+	}
+	regions = regions[:keep]
+
+	clear(d.Tiles)
+	d.Rooms = d.Rooms[:0]
+	for i, region := range regions {
+		room := model.Room{Shape: model.Cave, TopLeft: region[0], BottomRight: region[0]}
+		for _, c := range region {
+			d.Set(c, model.TileRoomFloor)
+			room.TopLeft.X, room.TopLeft.Y = min(room.TopLeft.X, c.X), min(room.TopLeft.Y, c.Y)
+			room.BottomRight.X, room.BottomRight.Y = max(room.BottomRight.X, c.X), max(room.BottomRight.Y, c.Y)
+		}
+		d.Rooms = append(d.Rooms, room)
+		g.emit(RoomPlaced{Room: i, Placed: room})
+		g.record(StepRoomPlaced, d)
+	}
+	return regions, nil
+}
+
+var dirs4 = []model.Cell{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
+
+// eachCell calls fn for every grid cell in row order.
+func (g *Generator) eachCell(fn func(model.Cell)) {
+	grid := g.cfg.Grid
+	for y := grid.MinY; y <= grid.MaxY; y++ {
+		for x := grid.MinX; x <= grid.MaxX; x++ {
+			fn(model.Cell{X: x, Y: y})
+		}
+	}
+}
+
+// CaveConnector joins the caves made by CavePlacer. Each cave after the
+// first is linked by a corridor from its nearest point to the caves
+// already joined, and one more corridor runs from the nearest grid edge to
+// the largest cave, whose edge cell becomes the only start. Corridors go
+// around every other room and its walls, such as those HybridPlacer
+// builds. Without caves, for instance after another placer, it does
+// nothing.
+type CaveConnector struct{}
+
+// Connect implements Connector.
+func (CaveConnector) Connect(s *State, d *model.Dungeon) error {
+	if len(s.caves) == 0 {
+		return nil
+	}
+	g := s.g
+
+	blocked := make(map[model.Cell]bool)
+	for _, r := range d.Rooms {
+		if r.Shape != model.Cave {
+			eachBoxCell(r, 1, func(c model.Cell) { blocked[c] = true })
+		}
+	}
+
+	joined := slices.Clone(border(s.caves[0]))
+	for i, region := range s.caves[1:] {
+		from, to := closestPair(border(region), joined)
+		path, err := g.findPath(from, to, blocked)
+		if err != nil {
+			return err
+		}
+		if path == nil {
+			g.emit(PathFailed{Room: i + 1, Door: to})
+			continue
+		}
+		g.emit(PathFound{Room: i + 1, From: from, Door: to, Length: len(path)})
+		g.carveOpen(d, i+1, path)
+		joined = append(append(joined, border(region)...), path...)
+	}
+
+	ok, err := g.carveEntrance(d, 0, s.caves[0], blocked)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return unreachableErr(d.Rooms, 0)
+	}
+	return nil
+}
+
+// carveEntrance carves a corridor to the cell of region nearest the grid
+// edge from the closest edge cell, which becomes the only start. i is the
+// room the corridor is reported for. It reports false if no path avoiding
+// blocked exists.
+func (g *Generator) carveEntrance(d *model.Dungeon, i int, region []model.Cell, blocked map[model.Cell]bool) (bool, error) {
+	grid := g.cfg.Grid
+	edgeDist := func(c model.Cell) int32 {
+		return min(c.X-grid.MinX, grid.MaxX-c.X, c.Y-grid.MinY, grid.MaxY-c.Y)
+	}
+	target := region[0]
+	for _, c := range border(region) {
+		if edgeDist(c) < edgeDist(target) {
+			target = c
+		}
+	}
+	path, src, err := g.findPathFrom(g.freeEdgeCells(blocked), target, blocked)
+	if err != nil || path == nil {
+		return false, err
+	}
+	g.emit(PathFound{Room: i, From: src, Door: target, Length: len(path)})
+	g.carveCorridor(d, src, nil)
+	g.carveOpen(d, i, path)
+	d.Starts = []model.Cell{src}
+	return true, nil
+}
+
+// carveOpen carves path as a corridor to cave i, leaving cave floor as it
+// is.
+func (g *Generator) carveOpen(d *model.Dungeon, i int, path []model.Cell) {
+	for _, c := range path {
+		g.carveCorridor(d, c, nil)
+	}
+	g.emit(CorridorCarved{Room: i, Path: path})
+	g.record(StepCorridorCarved, d)
+}
+
+// border returns the cells of region with a 4-neighbour outside it.
+func border(region []model.Cell) []model.Cell {
+	in := make(map[model.Cell]bool, len(region))
+	for _, c := range region {
+		in[c] = true
+	}
+	var out []model.Cell
+	for _, c := range region {
+		for _, di := range dirs4 {
+			if !in[model.Cell{X: c.X + di.X, Y: c.Y + di.Y}] {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// closestPair returns the cells of a and b with the smallest Manhattan
+// distance, preferring the earliest.
+func closestPair(a, b []model.Cell) (model.Cell, model.Cell) {
+	best := int32(-1)
+	var from, to model.Cell
+	for _, x := range a {
+		for _, y := range b {
+			if d := manhattan(x, y); best < 0 || d < best {
+				best, from, to = d, x, y
+			}
+		}
+	}
+	return from, to
+}
+
+// CaveWalls surrounds the floor of every Cave room with wall, including
+// diagonally, so the caves have solid outlines. Corridors are left open.
+type CaveWalls struct{}
+
+// BuildWalls implements WallBuilder.
+func (CaveWalls) BuildWalls(s *State, d *model.Dungeon) error {
+	for i, r := range d.Rooms {
+		if r.Shape != model.Cave {
+			continue
+		}
+		for y := r.TopLeft.Y; y <= r.BottomRight.Y; y++ {
+			for x := r.TopLeft.X; x <= r.BottomRight.X; x++ {
+				if d.At(model.Cell{X: x, Y: y}) != model.TileRoomFloor {
+					continue
+				}
+				for dy := int32(-1); dy <= 1; dy++ {
+					for dx := int32(-1); dx <= 1; dx++ {
+						w := model.Cell{X: x + dx, Y: y + dy}
+						if d.InBounds(w) && d.At(w) == model.TileEmpty {
+							d.Set(w, model.TileWall)
+							s.Emit(WallAdded{Room: i, Cell: w})
+						}
+					}
+				}
+			}
+		}
+		s.Record(StepWallsAdded, d)
+	}
+	return nil
+}
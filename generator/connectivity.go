@@ -0,0 +1,287 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// RepairStrategy is one way the connectivity pass can fix a room that is
+// not reachable from the starts.
+type RepairStrategy int
+
+const (
+	// RepairNone disables repair when it is the only strategy listed.
+	RepairNone RepairStrategy = iota
+	// RepairRelaxBuffer retries the path with a smaller CorridorBuff,
+	// one step at a time down to zero.
+	RepairRelaxBuffer
+	// RepairMoveDoor tries other cells of the room's wall as its door,
+	// where GenPaths could have put one.
+	RepairMoveDoor
+	// RepairDropRoom removes the room from the dungeon.
+	RepairDropRoom
+)
+
+// DefaultRepair is the policy used when Config.Repair is nil.
+var DefaultRepair = []RepairStrategy{RepairRelaxBuffer, RepairMoveDoor, RepairDropRoom}
+
+var repairName = map[RepairStrategy]string{
+	RepairNone:        "none",
+	RepairRelaxBuffer: "relax-buffer",
+	RepairMoveDoor:    "move-door",
+	RepairDropRoom:    "drop-room",
+}
+
+func (s RepairStrategy) String() string {
+	if name, ok := repairName[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s RepairStrategy) MarshalText() ([]byte, error) {
+	name, ok := repairName[s]
+	if !ok {
+		return nil, fmt.Errorf("unknown repair strategy %d", int(s))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *RepairStrategy) UnmarshalText(b []byte) error {
+	for id, name := range repairName {
+		if strings.EqualFold(name, string(b)) {
+			*s = id
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown repair strategy %q", string(b))
+}
+
+// maxDoorMoves bounds how many alternative doors RepairMoveDoor tries per
+// room; each try is a full path search.
+const maxDoorMoves = 16
+
+// RepairAction records one successful repair.
+type RepairAction struct {
+	// Room is the index of the room in placement order.
+	Room     int
+	Shape    model.RoomId
+	Strategy RepairStrategy
+	// Buffer is the CorridorBuff the path was found with.
+	Buffer int32
+	// Door is the room's door after the repair.
+	Door model.Cell
+}
+
+func (a RepairAction) String() string {
+	switch a.Strategy {
+	case RepairRelaxBuffer:
+		return fmt.Sprintf("room %d (%s): connected with corridor buffer %d", a.Room, a.Shape, a.Buffer)
+	case RepairMoveDoor:
+		return fmt.Sprintf("room %d (%s): moved door to %v", a.Room, a.Shape, a.Door)
+	case RepairDropRoom:
+		return fmt.Sprintf("room %d (%s): dropped", a.Room, a.Shape)
+	default:
+		return fmt.Sprintf("room %d (%s): %s", a.Room, a.Shape, a.Strategy)
+	}
+}
+
+// ConnectivityReport describes the connectivity pass of one Generate call.
+// Room indices refer to placement order, before any rooms were dropped.
+type ConnectivityReport struct {
+	// Unreachable lists the rooms that were not connected after corridor
+	// carving.
+	Unreachable []int
+	// Repairs lists what was done for each room that got fixed.
+	Repairs []RepairAction
+	// Failed lists the rooms that are still unreachable.
+	Failed []int
+}
+
+// Connectivity returns the report of the most recent Generate call.
+func (g *Generator) Connectivity() ConnectivityReport {
+	return g.report
+}
+
+func (g *Generator) repairPolicy() []RepairStrategy {
+	if g.cfg.Repair == nil {
+		return DefaultRepair
+	}
+	return g.cfg.Repair
+}
+
+// ensureConnected flood-fills from the starts, then repairs every room whose
+// door cannot be reached according to the repair policy. The rooms left
+// then get their other doors from openExtraDoors and store them all in
+// their Doors, and dropped rooms are removed from d.Rooms. Rooms that stay
+// unreachable are reported in an error wrapping ErrUnreachableRoom.
+func (g *Generator) ensureConnected(d *model.Dungeon, p *pathPlan) (ConnectivityReport, error) {
+	var report ConnectivityReport
+	d.Starts = p.starts
+	reach := d.Reachable()
+
+	dropped := make([]bool, len(p.rooms))
+	var failed []error
+	for i := range p.rooms {
+		if p.hasDoor[i] && reach[p.doors[i]] {
+			continue
+		}
+		report.Unreachable = append(report.Unreachable, i)
+
+		var action RepairAction
+		fixed := false
+		for _, s := range g.repairPolicy() {
+			var err error
+			fixed, action, err = g.repair(d, p, i, s)
+			if err != nil {
+				return report, err
+			}
+			if fixed {
+				break
+			}
+		}
+		if !fixed {
+			report.Failed = append(report.Failed, i)
+			failed = append(failed, unreachableErr(p.rooms, i))
+			continue
+		}
+
+		report.Repairs = append(report.Repairs, action)
+		if action.Strategy == RepairDropRoom {
+			dropped[i] = true
+			continue
+		}
+		d.Starts = p.starts
+		reach = d.Reachable()
+	}
+
+	if err := g.openExtraDoors(d, p, dropped); err != nil {
+		return report, err
+	}
+	d.Rooms = d.Rooms[:0:0]
+	for i, r := range p.rooms {
+		if !dropped[i] {
+			r.Doors = p.roomDoors(i)
+			d.Rooms = append(d.Rooms, r)
+		}
+	}
+	return report, errors.Join(failed...)
+}
+
+// repair applies one strategy to room i and reports whether it is now
+// connected (or, for RepairDropRoom, gone).
+func (g *Generator) repair(d *model.Dungeon, p *pathPlan, i int, s RepairStrategy) (bool, RepairAction, error) {
+	action := RepairAction{Room: i, Shape: p.rooms[i].Shape, Strategy: s, Buffer: g.cfg.CorridorBuff, Door: p.doors[i]}
+
+	switch s {
+	case RepairRelaxBuffer:
+		if !p.hasDoor[i] {
+			return false, action, nil
+		}
+		for buff := g.cfg.CorridorBuff - 1; buff >= 0; buff-- {
+			ok, err := g.connectFromNetwork(d, p, i, g.blockedMap(p, buff))
+			if err != nil || ok {
+				action.Buffer = buff
+				return ok, action, err
+			}
+		}
+
+	case RepairMoveDoor:
+		// Keep to the door rules: straight stretches of wall, spaced from
+		// the room's other doors.
+		others := slices.Clone(p.extra[i])
+		for _, w := range p.wanted[i] {
+			others = append(others, w.cell)
+		}
+		spacing := g.cfg.Doors.spacing()
+		var cands []model.Cell
+		for _, s := range g.doorSites(p.cells[i], p.edges[i]) {
+			if (!p.hasDoor[i] || s.cell != p.doors[i]) && spaced(s.cell, others, spacing) {
+				cands = append(cands, s.cell)
+			}
+		}
+		g.rng.Shuffle(len(cands), func(a, b int) { cands[a], cands[b] = cands[b], cands[a] })
+
+		old, hadDoor := p.doors[i], p.hasDoor[i]
+		for _, c := range cands[:min(len(cands), maxDoorMoves)] {
+			g.setDoor(d, p, i, c, true)
+			blocked := g.blockedMap(p, g.cfg.CorridorBuff)
+			ok, err := g.connectFromNetwork(d, p, i, blocked)
+			if err != nil {
+				return false, action, err
+			}
+			if ok {
+				p.blocked = blocked
+				action.Door = c
+				return true, action, nil
+			}
+			g.setDoor(d, p, i, c, false)
+		}
+		if hadDoor {
+			g.setDoor(d, p, i, old, true)
+		}
+
+	case RepairDropRoom:
+		if p.hasDoor[i] {
+			g.setDoor(d, p, i, p.doors[i], false)
+		}
+		return true, action, nil
+	}
+	return false, action, nil
+}
+
+// setDoor makes c the door of room i, or turns it back into a plain room
+// cell when on is false.
+func (g *Generator) setDoor(d *model.Dungeon, p *pathPlan, i int, c model.Cell, on bool) {
+	if on {
+		if p.hasDoor[i] {
+			g.setDoor(d, p, i, p.doors[i], false)
+		}
+		p.doors[i], p.hasDoor[i] = c, true
+		delete(p.solid, c)
+		d.Set(c, model.TileDoor)
+		g.emit(DoorChosen{Room: i, Door: c})
+		g.record(StepDoorChosen, d)
+		return
+	}
+	p.hasDoor[i] = false
+	p.solid[c] = true
+	d.Set(c, model.TileEmpty)
+}
+
+// connectFromNetwork carves the shortest path from any corridor cell to
+// room i's door. With no corridors yet it starts from the free grid edge
+// instead and records the new start.
+func (g *Generator) connectFromNetwork(d *model.Dungeon, p *pathPlan, i int, blocked map[model.Cell]bool) (bool, error) {
+	sources := p.corridorCells
+	fromEdge := len(sources) == 0
+	if fromEdge {
+		sources = g.freeEdgeCells(p.solid)
+	}
+
+	path, src, err := g.findPathFrom(sources, p.doors[i], blocked)
+	if err != nil {
+		return false, err
+	}
+	if path == nil {
+		g.emit(PathFailed{Room: i, Door: p.doors[i]})
+		return false, nil
+	}
+	g.emit(PathFound{Room: i, From: src, Door: p.doors[i], Length: len(path)})
+	if fromEdge {
+		p.starts = append(p.starts, src)
+		d.Starts = p.starts
+		if d.At(src) != model.TileDoor {
+			g.carveCorridor(d, src, blocked)
+		}
+		p.addCorridor(src)
+	}
+	g.carvePath(d, p, i, path, blocked)
+	return true, nil
+}
@@ -0,0 +1,197 @@
+package generator
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// defaultDoorSpacing is the DoorConfig.Spacing used when it is zero.
+const defaultDoorSpacing = 4
+
+// DoorConfig controls the doors GenPaths gives each room. Zero fields select
+// the defaults.
+type DoorConfig struct {
+	// Counts is the range of doors per room by shape; shapes left out get
+	// one door. GraphConnector and MSTConnector give a room one door per
+	// corridor instead, so Validate rejects Counts in those modes.
+	Counts map[model.RoomId]DoorRange
+	// Spacing is the least distance between two doors of one room, in
+	// tiles along either axis; 0 selects 4. GraphConnector and
+	// MSTConnector keep it too.
+	Spacing int32
+}
+
+// DoorRange is an inclusive range of door counts. A zero Min selects 1 and
+// a zero Max selects Min.
+type DoorRange struct {
+	Min int32
+	Max int32
+}
+
+func (c DoorConfig) spacing() int32 {
+	if c.Spacing > 0 {
+		return c.Spacing
+	}
+	return defaultDoorSpacing
+}
+
+// doorCount draws how many doors a room of shape gets.
+func (g *Generator) doorCount(shape model.RoomId) int {
+	r := g.cfg.Doors.Counts[shape]
+	lo := max(r.Min, 1)
+	hi := max(r.Max, lo)
+	if hi == lo {
+		return int(lo)
+	}
+	return int(lo + g.rng.Int31n(hi-lo+1))
+}
+
+// doorSite is an edge cell a door may go on, and the direction the door
+// opens to.
+type doorSite struct {
+	cell model.Cell
+	out  model.Cell
+}
+
+// doorSites returns the edge cells of a room footprint where a door sits in
+// a straight stretch of wall: off the grid edge, open on exactly one side,
+// with both neighbours along the wall open on the same side, and with room
+// in the grid for a corridor to clear CorridorBuff on that side. Corners
+// and the steps of diagonal edges, such as triangle slopes, are left out.
+// When no cell qualifies the inner edge cells are returned, or failing that
+// all of them, with no direction.
+func (g *Generator) doorSites(cells, edges []model.Cell) []doorSite {
+	in := make(map[model.Cell]bool, len(cells))
+	for _, c := range cells {
+		in[c] = true
+	}
+	open := func(c, d model.Cell) bool {
+		return !in[model.Cell{X: c.X + d.X, Y: c.Y + d.Y}]
+	}
+
+	var sites []doorSite
+	for _, c := range g.innerCells(edges) {
+		var out model.Cell
+		n := 0
+		for _, d := range dirs4 {
+			if open(c, d) {
+				out, n = d, n+1
+			}
+		}
+		if n != 1 {
+			continue
+		}
+		reach := model.Cell{X: c.X + out.X*(g.cfg.CorridorBuff+1), Y: c.Y + out.Y*(g.cfg.CorridorBuff+1)}
+		straight := g.cfg.Grid.InBounds(reach)
+		for _, side := range []model.Cell{{X: out.Y, Y: out.X}, {X: -out.Y, Y: -out.X}} {
+			s := model.Cell{X: c.X + side.X, Y: c.Y + side.Y}
+			straight = straight && in[s] && open(s, out)
+		}
+		if straight {
+			sites = append(sites, doorSite{cell: c, out: out})
+		}
+	}
+	if len(sites) > 0 {
+		return sites
+	}
+
+	fallback := g.innerCells(edges)
+	if len(fallback) == 0 {
+		fallback = edges
+	}
+	for _, c := range fallback {
+		sites = append(sites, doorSite{cell: c})
+	}
+	return sites
+}
+
+// facing reports whether s opens toward target.
+func (s doorSite) facing(target model.Cell) bool {
+	return s.out.X*(target.X-s.cell.X)+s.out.Y*(target.Y-s.cell.Y) > 0
+}
+
+// bestSite returns the site that opens toward target, nearest to it, among
+// those ok accepts; ties go to the earliest site. It reports false when ok
+// accepts none.
+func bestSite(sites []doorSite, target model.Cell, ok func(doorSite) bool) (doorSite, bool) {
+	var best doorSite
+	found, bestFacing := false, false
+	for _, s := range sites {
+		if !ok(s) {
+			continue
+		}
+		f := s.facing(target)
+		if !found || (f && !bestFacing) || (f == bestFacing && manhattan(s.cell, target) < manhattan(best.cell, target)) {
+			best, found, bestFacing = s, true, f
+		}
+	}
+	return best, found
+}
+
+// spaced reports whether c is at least spacing from every cell of doors,
+// along either axis.
+func spaced(c model.Cell, doors []model.Cell, spacing int32) bool {
+	for _, d := range doors {
+		if max(c.X-d.X, d.X-c.X, c.Y-d.Y, d.Y-c.Y) < spacing {
+			return false
+		}
+	}
+	return true
+}
+
+// neighbours returns the other rooms by the distance of their centres from
+// rooms[i], nearest first and ties in room order.
+func neighbours(rooms []model.Room, i int) []int {
+	var out []int
+	for j := range rooms {
+		if j != i {
+			out = append(out, j)
+		}
+	}
+	centre := roomCentre(rooms[i])
+	slices.SortStableFunc(out, func(a, b int) int {
+		return cmp.Compare(manhattan(centre, roomCentre(rooms[a])), manhattan(centre, roomCentre(rooms[b])))
+	})
+	return out
+}
+
+// nearestEdgeCell returns the cell on the grid edge closest to c.
+func (g *Generator) nearestEdgeCell(c model.Cell) model.Cell {
+	grid := g.cfg.Grid
+	best := model.Cell{X: grid.MinX, Y: c.Y}
+	for _, e := range []model.Cell{{X: grid.MaxX, Y: c.Y}, {X: c.X, Y: grid.MinY}, {X: c.X, Y: grid.MaxY}} {
+		if manhattan(c, e) < manhattan(c, best) {
+			best = e
+		}
+	}
+	return best
+}
+
+// pickDoors chooses up to n doors for rooms[i] from sites. The first faces
+// the nearest other room, the next the second nearest and so on, wrapping
+// around; a room alone faces the nearest grid edge. Each door is at least
+// Config.Doors.Spacing from the ones before it, and fewer than n are
+// returned when no site is left that far away. faces holds the room each
+// door faces, or -1 for the grid edge.
+func (g *Generator) pickDoors(rooms []model.Room, i int, sites []doorSite, n int) (doors []model.Cell, faces []int) {
+	near := neighbours(rooms, i)
+	spacing := g.cfg.Doors.spacing()
+	for k := range n {
+		face, target := -1, g.nearestEdgeCell(roomCentre(rooms[i]))
+		if len(near) > 0 {
+			face = near[k%len(near)]
+			target = roomCentre(rooms[face])
+		}
+		s, ok := bestSite(sites, target, func(s doorSite) bool {
+			return spaced(s.cell, doors, spacing)
+		})
+		if !ok {
+			break
+		}
+		doors = append(doors, s.cell)
+		faces = append(faces, face)
+	}
+	return doors, faces
+}
@@ -0,0 +1,28 @@
+package generator
+
+import "errors"
+
+var (
+	// ErrNoShapes is returned when Config.RoomShapes is empty.
+	ErrNoShapes = errors.New("no room shapes configured")
+	// ErrNoRoomsPlaced is returned when rooms were requested but none
+	// could be placed within the placement constraints.
+	ErrNoRoomsPlaced = errors.New("no rooms could be placed")
+	// ErrUnreachableRoom is returned when a room could not be connected to
+	// the corridor network. The dungeon returned alongside it is complete
+	// apart from the missing corridors.
+	ErrUnreachableRoom = errors.New("room is unreachable")
+	// ErrBudgetExceeded is returned when generation runs past
+	// Config.MaxIterations or Config.Timeout.
+	ErrBudgetExceeded = errors.New("generation budget exceeded")
+	// ErrNoGraph is returned when ModeGraph has no Config.Graph nodes to
+	// lay out.
+	ErrNoGraph = errors.New("no room graph to lay out")
+	// ErrNoSample is returned when ModeWFC has no Config.WFC.Sample to
+	// learn from.
+	ErrNoSample = errors.New("no sample to learn from")
+	// ErrContradiction is returned when Wave Function Collapse runs into a
+	// contradiction it cannot backtrack out of within
+	// Config.WFC.Backtracks.
+	ErrContradiction = errors.New("wave function collapse reached a contradiction")
+)
@@ -0,0 +1,183 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Event is something that happened during generation. It is one of
+// RoomPlaced, RoomRejected, DoorChosen, PathFound, PathFailed,
+// CorridorCarved, EdgeJoined or WallAdded; observers tell them apart with
+// a type switch.
+//
+// Room indexes refer to the rooms of the call that sends the event. During
+// Generate that is placement order, except for WallAdded, which indexes
+// the final Dungeon.Rooms after repair has dropped any rooms.
+type Event interface {
+	fmt.Stringer
+	isEvent()
+}
+
+// RoomPlaced is sent when Rooms accepts a room.
+type RoomPlaced struct {
+	Room   int
+	Placed model.Room
+}
+
+// RejectReason says why a candidate room was not placed.
+type RejectReason int
+
+const (
+	// RejectAreaCap means the room would push the combined room area past
+	// Config.MaxTotalRoomAreaFraction.
+	RejectAreaCap RejectReason = iota
+	// RejectNearRoom means the room is within Config.MinRoomGap of a
+	// placed room.
+	RejectNearRoom
+	// RejectNearStart means the room is within Config.MinRoomGap of a
+	// corridor start.
+	RejectNearStart
+	// RejectNoCaveDoor means a built room of ModeHybrid has no side a
+	// door could open onto cave floor from.
+	RejectNoCaveDoor
+	// RejectCaveSplit means a built room of ModeHybrid would cut a cave in
+	// two or cover it completely.
+	RejectCaveSplit
+	// RejectOutOfBounds means the room leaves no space inside the grid
+	// edge for its walls.
+	RejectOutOfBounds
+)
+
+var rejectReasonName = map[RejectReason]string{
+	RejectAreaCap:     "area cap",
+	RejectNearRoom:    "too close to a room",
+	RejectNearStart:   "too close to a start",
+	RejectNoCaveDoor:  "no door onto the cave",
+	RejectCaveSplit:   "would split a cave",
+	RejectOutOfBounds: "too close to the grid edge",
+}
+
+func (r RejectReason) String() string {
+	if name, ok := rejectReasonName[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// RoomRejected is sent for every candidate room Rooms turns down.
+type RoomRejected struct {
+	Candidate model.Room
+	Reason    RejectReason
+}
+
+// DoorChosen is sent when a room gets a door, including each door tried
+// while repairing an unreachable room.
+type DoorChosen struct {
+	Room int
+	Door model.Cell
+}
+
+// PathFound is sent when a corridor route to a room's door is found. From
+// is the corridor or grid edge cell it starts at and Length the number of
+// cells after it.
+type PathFound struct {
+	Room   int
+	From   model.Cell
+	Door   model.Cell
+	Length int
+}
+
+// PathFailed is sent when no route to a room's door exists with the
+// current buffer and door.
+type PathFailed struct {
+	Room int
+	Door model.Cell
+}
+
+// CorridorCarved is sent after the cells of a path have been carved. Path
+// must not be modified.
+type CorridorCarved struct {
+	Room int
+	Path []model.Cell
+}
+
+// EdgeJoined is sent when the corridor for edge Edge of Config.Graph has
+// been carved, between the doors of rooms From and To, in that order.
+type EdgeJoined struct {
+	Edge     int
+	From, To int
+	Kind     DoorKind
+	Doors    [2]model.Cell
+}
+
+// WallAdded is sent for each wall tile drawn around a room.
+type WallAdded struct {
+	Room int
+	Cell model.Cell
+}
+
+func (RoomPlaced) isEvent()     {}
+func (RoomRejected) isEvent()   {}
+func (DoorChosen) isEvent()     {}
+func (PathFound) isEvent()      {}
+func (PathFailed) isEvent()     {}
+func (CorridorCarved) isEvent() {}
+func (EdgeJoined) isEvent()     {}
+func (WallAdded) isEvent()      {}
+
+func (e RoomPlaced) String() string {
+	return fmt.Sprintf("room %d placed: %s at %v", e.Room, e.Placed.Shape, e.Placed.TopLeft)
+}
+
+func (e RoomRejected) String() string {
+	return fmt.Sprintf("room rejected (%s): %s at %v", e.Reason, e.Candidate.Shape, e.Candidate.TopLeft)
+}
+
+func (e DoorChosen) String() string {
+	return fmt.Sprintf("room %d door at %v", e.Room, e.Door)
+}
+
+func (e PathFound) String() string {
+	return fmt.Sprintf("room %d path found from %v, %d cells", e.Room, e.From, e.Length)
+}
+
+func (e PathFailed) String() string {
+	return fmt.Sprintf("room %d no path to door at %v", e.Room, e.Door)
+}
+
+func (e CorridorCarved) String() string {
+	return fmt.Sprintf("room %d corridor carved, %d cells", e.Room, len(e.Path))
+}
+
+func (e EdgeJoined) String() string {
+	return fmt.Sprintf("edge %d joined room %d to room %d (%s) at %v and %v", e.Edge, e.From, e.To, e.Kind, e.Doors[0], e.Doors[1])
+}
+
+func (e WallAdded) String() string {
+	return fmt.Sprintf("room %d wall at %v", e.Room, e.Cell)
+}
+
+// Observer receives generation events. Observe is called synchronously on
+// the generating goroutine, so it should return quickly.
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc adapts a function to an Observer.
+type ObserverFunc func(Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) { f(e) }
+
+// Observe adds o to the observers sent every event from Generate, Rooms,
+// GenPaths and AddRoomEdges, in the order they were added.
+func (g *Generator) Observe(o Observer) {
+	g.observers = append(g.observers, o)
+}
+
+func (g *Generator) emit(e Event) {
+	for _, o := range g.observers {
+		o.Observe(e)
+	}
+}
@@ -0,0 +1,147 @@
+package generator_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// testSample is a small WFC sample of two rooms joined by a corridor.
+var testSample = []string{
+	"▒▒▒▒▒▒▒▒▒▒",
+	"▒...▒....▒",
+	"▒...+....▒",
+	"▒...▒....▒",
+	"▒▒+▒▒▒▒+▒▒",
+	" ▒#▒  ▒#▒ ",
+	" ▒#####▒#▒",
+	" ▒▒▒▒▒▒▒#▒",
+}
+
+// testGraph is a six-room graph with a hub, a side branch and a boss room.
+var testGraph = generator.RoomGraph{
+	Nodes: []generator.GraphNode{
+		{Name: "entrance", W: 5, H: 4, Tags: []string{"start"}},
+		{Name: "hub", Shape: model.Circle, W: 9},
+		{Name: "side1", Shape: model.Square},
+		{Name: "side2", Shape: model.Triangle},
+		{Name: "side3"},
+		{Name: "boss", W: 12, H: 8, Tags: []string{"boss"}},
+	},
+	Edges: []generator.GraphEdge{
+		{From: "entrance", To: "hub"},
+		{From: "hub", To: "side1"},
+		{From: "hub", To: "side2", Door: generator.DoorSecret},
+		{From: "hub", To: "side3"},
+		{From: "side3", To: "boss", Door: generator.DoorLocked},
+	},
+}
+
+// testConfig returns the command line defaults for mode, with the graph
+// and sample the graph and wfc modes need.
+func testConfig(t *testing.T, mode generator.Mode) generator.Config {
+	t.Helper()
+	cfg := generator.Config{
+		Mode:         mode,
+		Grid:         model.Grid{MinX: -50, MaxX: 50, MinY: -20, MaxY: 20},
+		MaxRooms:     20,
+		RoomShapes:   []model.RoomId{model.Rectangle, model.Circle, model.Square, model.Triangle},
+		CorridorW:    2,
+		CorridorBuff: 1,
+		Graph:        testGraph,
+	}
+	for _, line := range testSample {
+		var row []model.Tile
+		for _, r := range line {
+			tile, ok := model.ParseRune(r)
+			if !ok {
+				t.Fatalf("sample rune %q is not a tile", r)
+			}
+			row = append(row, tile)
+		}
+		cfg.WFC.Sample = append(cfg.WFC.Sample, row)
+	}
+	// Read across the sample's edges, so its corridors run on instead of
+	// ending where the sample does.
+	cfg.WFC.PeriodicInput = true
+	return cfg
+}
+
+// generate runs the generator for cfg and seed. Unreachable rooms are
+// allowed, as the command line allows them.
+func generate(t *testing.T, cfg generator.Config, seed int64) model.Dungeon {
+	t.Helper()
+	d, err := generator.New(cfg, seed).Generate(context.Background())
+	if err != nil && !errors.Is(err, generator.ErrUnreachableRoom) {
+		t.Fatalf("Generate: %v", err)
+	}
+	return d
+}
+
+func TestGenerateValid(t *testing.T) {
+	for _, name := range generator.ModeNames() {
+		t.Run(name, func(t *testing.T) {
+			var mode generator.Mode
+			if err := mode.UnmarshalText([]byte(name)); err != nil {
+				t.Fatal(err)
+			}
+			cfg := testConfig(t, mode)
+			for seed := int64(1); seed <= 10; seed++ {
+				d, err := generator.New(cfg, seed).Generate(context.Background())
+				if errors.Is(err, generator.ErrContradiction) {
+					// A sample may run into a dead end; that is reported,
+					// not a broken dungeon.
+					continue
+				}
+				if err != nil && !errors.Is(err, generator.ErrUnreachableRoom) {
+					t.Fatalf("seed %d: Generate: %v", seed, err)
+				}
+				if err := d.Validate(); err != nil {
+					t.Errorf("seed %d: %s", seed, strings.ReplaceAll(err.Error(), "\n", "; "))
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateValidMSTLoops(t *testing.T) {
+	for _, loops := range []float64{0.5, 1} {
+		cfg := testConfig(t, generator.ModeMST)
+		cfg.Loops = loops
+		for seed := int64(1); seed <= 10; seed++ {
+			d := generate(t, cfg, seed)
+			if err := d.Validate(); err != nil {
+				t.Errorf("loops %v, seed %d: %s", loops, seed, strings.ReplaceAll(err.Error(), "\n", "; "))
+			}
+		}
+	}
+}
+
+func TestRoomsRejectOutOfBounds(t *testing.T) {
+	for _, mode := range []generator.Mode{generator.ModeRooms, generator.ModeHybrid} {
+		cfg := testConfig(t, mode)
+		g := generator.New(cfg, 1)
+		edge := 0
+		g.Observe(generator.ObserverFunc(func(e generator.Event) {
+			r, ok := e.(generator.RoomRejected)
+			if !ok || r.Reason != generator.RejectOutOfBounds {
+				return
+			}
+			edge++
+			c := r.Candidate
+			if cfg.Grid.RoomInBoundsWithPadding(c.TopLeft, 2) && cfg.Grid.RoomInBoundsWithPadding(c.BottomRight, 2) {
+				t.Errorf("%s: %v rejected as out of bounds but has room for its walls", mode, c)
+			}
+		}))
+		if _, err := g.Generate(context.Background()); err != nil && !errors.Is(err, generator.ErrUnreachableRoom) {
+			t.Fatalf("%s: Generate: %v", mode, err)
+		}
+		if edge == 0 {
+			t.Errorf("%s: no candidate was rejected at the grid edge", mode)
+		}
+	}
+}
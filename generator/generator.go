@@ -1,6 +1,8 @@
 package generator
 
 import (
+	"context"
+	"errors"
 	"math/rand"
 	"time"
 
@@ -8,22 +10,75 @@ import (
 )
 
 type Config struct {
-	Grid         model.Grid
-	MaxRooms     int
-	RoomShapes   []model.RoomId
-	RoomMinW     int32
-	RoomMaxW     int32
-	RoomMinH     int32
-	RoomMaxH     int32
+	// Mode selects the generation algorithm; the zero value is ModeRooms.
+	Mode Mode
+
+	Grid     model.Grid
+	MaxRooms int
+	// RoomShapes are the shapes rooms are drawn from. It may be empty in
+	// the modes that make no shaped rooms: cave, walk, wfc and graph.
+	RoomShapes []model.RoomId
+	RoomMinW   int32
+	RoomMaxW   int32
+	RoomMinH   int32
+	RoomMaxH   int32
+	// CorridorW is the corridor thickness in tiles; 0 selects 1.
 	CorridorW    int32
 	CorridorBuff int32
+
+	// MaxRoomAreaFraction caps each room's bounding box as a fraction of
+	// the grid area; 0 selects 0.05.
+	MaxRoomAreaFraction float64
+	// MaxTotalRoomAreaFraction caps the combined area of all rooms as a
+	// fraction of the grid area; 0 selects 0.75.
+	MaxTotalRoomAreaFraction float64
+	// MinRoomGap is the minimum number of tiles between room bounding
+	// boxes; 0 selects 4.
+	MinRoomGap int32
+	// ShapeSizes overrides the size limits and distribution per shape.
+	ShapeSizes map[model.RoomId]SizeRule
+	// Doors sets how many doors GenPaths gives each room and where.
+	Doors DoorConfig
+
+	// MaxIterations bounds the work of one Generate call, counted in room
+	// placement attempts, perimeter probes and path search steps; 0 means
+	// no limit.
+	MaxIterations int
+	// Timeout bounds the wall-clock time of one Generate call; 0 means no
+	// limit.
+	Timeout time.Duration
+
+	// Cave controls the cellular automaton of ModeCave.
+	Cave CaveConfig
+	// Walk controls the random walkers of ModeWalk.
+	Walk WalkConfig
+	// Graph is the room graph ModeGraph lays out.
+	Graph RoomGraph
+	// WFC controls the Wave Function Collapse of ModeWFC.
+	WFC WFCConfig
+	// Loops is the fraction, from 0 to 1, of the Delaunay edges left out
+	// of the spanning tree that ModeMST adds back as loops; 0 adds none.
+	Loops float64
+
+	// Repair lists the strategies tried, in order, on each room left
+	// unreachable after corridor carving. nil selects DefaultRepair;
+	// RepairNone on its own disables repair.
+	Repair []RepairStrategy
 }
 
 type Generator struct {
-	cfg Config
-	rng *rand.Rand
+	cfg    Config
+	rng    *rand.Rand
+	budget *budget
+	report ConnectivityReport
+
+	recorder  Recorder
+	observers []Observer
+	pipeline  *Pipeline
 }
 
+// New returns a Generator for cfg. The same seed and cfg always generate
+// the same dungeon; a zero seed is replaced with one from the current time.
 func New(cfg Config, seed int64) *Generator {
 	if seed == 0 {
 		seed = time.Now().UnixNano()
@@ -34,13 +89,34 @@ func New(cfg Config, seed int64) *Generator {
 	}
 }
 
-func (g *Generator) Generate() model.Dungeon {
-	d := model.NewDungeon(g.cfg.Grid)
-	rooms := g.Rooms(d.Starts, g.cfg.MaxRooms)
-	d.Rooms = rooms
-	starts := g.GenPaths(&d, rooms)
-	d.Starts = starts
-	g.AddRoomEdges(&d, rooms)
+// Generate builds a dungeon by running the pipeline for Config.Mode, or the
+// pipeline set with UsePipeline, over an empty grid. It stops early with ctx's error
+// when ctx is done and with ErrBudgetExceeded when it runs past
+// Config.MaxIterations or Config.Timeout. An invalid Config is reported as
+// a ValidationError.
+//
+// In the built-in pipelines a connectivity pass after corridor carving
+// flood-fills from the starts and repairs unreachable rooms according to
+// Config.Repair; Connectivity reports what it did. If some rooms still
+// could not be connected the complete dungeon is returned together with an
+// error wrapping ErrUnreachableRoom; for every other error the returned
+// dungeon is empty.
+func (g *Generator) Generate(ctx context.Context) (model.Dungeon, error) {
+	if err := g.cfg.Validate(); err != nil {
+		return model.Dungeon{}, err
+	}
+	g.budget = newBudget(ctx, g.cfg)
+	g.report = ConnectivityReport{}
+	defer func() { g.budget = nil }()
 
-	return d
+	p := g.cfg.Mode.Pipeline()
+	if g.pipeline != nil {
+		p = *g.pipeline
+	}
+	d := model.NewDungeon(g.cfg.Grid)
+	err := p.Run(&State{Config: g.cfg, Rand: g.rng, g: g}, &d)
+	if err != nil && !errors.Is(err, ErrUnreachableRoom) {
+		return model.Dungeon{}, err
+	}
+	return d, err
 }
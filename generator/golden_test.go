@@ -0,0 +1,61 @@
+package generator_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestDeterministic(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3} {
+		a := generate(t, testConfig(t, generator.ModeRooms), seed)
+		b := generate(t, testConfig(t, generator.ModeRooms), seed)
+		if a.Fingerprint() != b.Fingerprint() {
+			t.Errorf("seed %d: fingerprints %s and %s differ", seed, a.Fingerprint(), b.Fingerprint())
+		}
+	}
+}
+
+// TestGolden pins the fingerprint and map of fixed seeds in every mode, so
+// a change that moves a single tile for a given seed and config shows up.
+// Run with -update after a deliberate change to the output.
+func TestGolden(t *testing.T) {
+	r := render.New(render.Options{Border: true})
+	for _, name := range generator.ModeNames() {
+		var mode generator.Mode
+		if err := mode.UnmarshalText([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		for _, seed := range []int64{1, 2} {
+			t.Run(fmt.Sprintf("%s-%d", name, seed), func(t *testing.T) {
+				d := generate(t, testConfig(t, mode), seed)
+				got := fmt.Sprintf("fingerprint: %s\n\n%s", d.Fingerprint(), r.String(&d))
+
+				path := filepath.Join("testdata", fmt.Sprintf("%s-%d.golden", name, seed))
+				if *update {
+					if err := os.MkdirAll("testdata", 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Errorf("%s changed; run go test -update if that is intended:\ngot:\n%s\nwant:\n%s", path, got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package generator
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// DoorKind is the kind of door at both ends of a GraphEdge. Every kind is
+// drawn as model.TileDoor; observers tell them apart by EdgeJoined.Kind.
+type DoorKind int
+
+const (
+	// DoorPlain is an ordinary door.
+	DoorPlain DoorKind = iota
+	// DoorLocked is a door that needs a key.
+	DoorLocked
+	// DoorSecret is a hidden door.
+	DoorSecret
+)
+
+var doorKindName = map[DoorKind]string{
+	DoorPlain:  "door",
+	DoorLocked: "locked",
+	DoorSecret: "secret",
+}
+
+func (k DoorKind) String() string {
+	if name, ok := doorKindName[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k DoorKind) MarshalText() ([]byte, error) {
+	name, ok := doorKindName[k]
+	if !ok {
+		return nil, fmt.Errorf("unknown door kind %d", int(k))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *DoorKind) UnmarshalText(b []byte) error {
+	for id, name := range doorKindName {
+		if strings.EqualFold(name, string(b)) {
+			*k = id
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown door kind %q", string(b))
+}
+
+// RoomGraph describes a dungeon as rooms and the corridors between them,
+// for ModeGraph to lay out. The first node is the entrance, and every node
+// must be reachable from it along the edges.
+type RoomGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// GraphNode is one room of a RoomGraph.
+type GraphNode struct {
+	// Name identifies the node in Edges and must be unique.
+	Name string
+	// Shape is the room's shape; the zero value is model.Rectangle.
+	Shape model.RoomId
+	// W and H are the size of the room's bounding box; 0 draws that side
+	// as RandomRoom does. Circles and squares use the larger side for
+	// both.
+	W, H int32
+	// Tags are labels such as "boss" for the caller; the generator does
+	// not read them.
+	Tags []string
+}
+
+// GraphEdge joins two nodes of a RoomGraph, named by GraphNode.Name, with
+// a corridor that has a door of kind Door at each end.
+type GraphEdge struct {
+	From, To string
+	Door     DoorKind
+}
+
+// index returns the position of every node by name.
+func (rg RoomGraph) index() map[string]int {
+	idx := make(map[string]int, len(rg.Nodes))
+	for i, n := range rg.Nodes {
+		idx[n.Name] = i
+	}
+	return idx
+}
+
+// order returns the nodes in breadth-first order from the first, and
+// whether every node was reached. Neighbours are visited in edge order.
+func (rg RoomGraph) order() ([]int, bool) {
+	if len(rg.Nodes) == 0 {
+		return nil, true
+	}
+	adj := rg.adjacent()
+	seen := make([]bool, len(rg.Nodes))
+	seen[0] = true
+	order := []int{0}
+	for k := 0; k < len(order); k++ {
+		for _, j := range adj[order[k]] {
+			if !seen[j] {
+				seen[j] = true
+				order = append(order, j)
+			}
+		}
+	}
+	return order, len(order) == len(rg.Nodes)
+}
+
+// adjacent returns the neighbours of every node in edge order, skipping
+// edges that name unknown nodes.
+func (rg RoomGraph) adjacent() [][]int {
+	idx := rg.index()
+	adj := make([][]int, len(rg.Nodes))
+	for _, e := range rg.Edges {
+		u, okU := idx[e.From]
+		v, okV := idx[e.To]
+		if okU && okV {
+			adj[u] = append(adj[u], v)
+			adj[v] = append(adj[v], u)
+		}
+	}
+	return adj
+}
+
+// Layout tuning for GraphPlacer.
+const (
+	// graphLayoutTries is how many times the whole layout is started over
+	// before giving up.
+	graphLayoutTries = 50
+	// graphCandidates is how many positions are tried for each room.
+	graphCandidates = 40
+)
+
+// graphDirs are 16 directions evenly around the circle, as unit vectors
+// scaled by 1000, so rooms are stepped apart in integer arithmetic and lay
+// out the same on every platform.
+var graphDirs = [16][2]int32{
+	{1000, 0}, {924, 383}, {707, 707}, {383, 924},
+	{0, 1000}, {-383, 924}, {-707, 707}, {-924, 383},
+	{-1000, 0}, {-924, -383}, {-707, -707}, {-383, -924},
+	{0, -1000}, {383, -924}, {707, -707}, {924, -383},
+}
+
+// clearance returns how far apart along dir the centres of boxes of sizes a
+// and b must be for the boxes to be gap apart on one axis.
+func clearance(a, b [2]int32, dir [2]int32, gap int32) int32 {
+	best := int32(-1)
+	for axis := range 2 {
+		v := max(dir[axis], -dir[axis])
+		if v == 0 {
+			continue
+		}
+		need := ((a[axis]+b[axis]+1)/2 + gap) * 1000
+		if d := (need + v - 1) / v; best < 0 || d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// scaleDir returns v*dist/1000 rounded half away from zero.
+func scaleDir(v, dist int32) int32 {
+	if v < 0 {
+		return -((-v*dist + 500) / 1000)
+	}
+	return (v*dist + 500) / 1000
+}
+
+// GraphPipeline lays out Config.Graph with GraphPlacer, joins its edges
+// with GraphConnector and walls the rooms in.
+func GraphPipeline() Pipeline {
+	return NewPipeline(GraphPlacer{}, GraphConnector{}, RoomWalls{})
+}
+
+// GraphPlacer lays out the nodes of Config.Graph as rooms, in the same
+// order, so d.Rooms[i] is the room of Config.Graph.Nodes[i]. Rooms are
+// placed breadth first from the entrance, each in one of 16 directions
+// from a room it is joined to and a random distance past clearing it,
+// picking from several candidates the one closest to all of its placed
+// neighbours. Rooms keep Config.MinRoomGap apart, and always enough space
+// for a corridor with Config.CorridorBuff clearance on both sides; when a
+// room does not fit the layout starts over with shorter steps, and after
+// repeated failures PlaceRooms returns an error wrapping ErrNoRoomsPlaced.
+// Graphs whose rooms cover too much of the grid still fail that way.
+type GraphPlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (GraphPlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	graph := s.Config.Graph
+	if len(graph.Nodes) == 0 {
+		return ErrNoGraph
+	}
+	g := s.g
+	sizes := make([][2]int32, len(graph.Nodes))
+	for i, n := range graph.Nodes {
+		sizes[i] = g.nodeSize(n)
+	}
+	for try := range graphLayoutTries {
+		// Later attempts step less far from the linked room, packing the
+		// layout tighter for graphs that barely fit.
+		rooms, err := g.layoutGraph(graph, sizes, graphLayoutTries-try)
+		if err != nil {
+			return err
+		}
+		if rooms == nil {
+			continue
+		}
+		d.Rooms = rooms
+		for i, r := range rooms {
+			g.emit(RoomPlaced{Room: i, Placed: r})
+			g.record(StepRoomPlaced, d)
+		}
+		s.graph = &graph
+		return nil
+	}
+	return fmt.Errorf("graph does not fit the grid: %w", ErrNoRoomsPlaced)
+}
+
+// nodeSize returns the bounding box size of n's room.
+func (g *Generator) nodeSize(n GraphNode) [2]int32 {
+	w, h := n.W, n.H
+	if w == 0 || h == 0 {
+		rw, rh := g.roomDimensions(n.Shape)
+		w, h = cmp.Or(w, rw), cmp.Or(h, rh)
+	}
+	if n.Shape == model.Circle || n.Shape == model.Square {
+		w = max(w, h)
+		h = w
+	}
+	return [2]int32{w, h}
+}
+
+// layoutGraph makes one attempt at placing every node of graph and returns
+// the rooms, or nil when some room did not fit. Rooms step up to
+// 2*gap*slack/graphLayoutTries tiles beyond clearing a linked room.
+func (g *Generator) layoutGraph(graph RoomGraph, sizes [][2]int32, slack int) ([]model.Room, error) {
+	grid := g.cfg.Grid
+	gap := max(g.minRoomGap(), 2*g.cfg.CorridorBuff+max(g.cfg.CorridorW, 1))
+	adj := graph.adjacent()
+	order, _ := graph.order()
+
+	rooms := make([]model.Room, len(graph.Nodes))
+	placed := make([]bool, len(graph.Nodes))
+	// Leave room for the walls inside the grid edge.
+	fits := func(r model.Room) bool {
+		return grid.RoomInBoundsWithPadding(r.TopLeft, 2) && grid.RoomInBoundsWithPadding(r.BottomRight, 2)
+	}
+	boxAt := func(i int, centre model.Cell) model.Room {
+		w, h := sizes[i][0], sizes[i][1]
+		tl := model.Cell{X: centre.X - w/2, Y: centre.Y - h/2}
+		return model.Room{Shape: graph.Nodes[i].Shape, TopLeft: tl, BottomRight: model.Cell{X: tl.X + w - 1, Y: tl.Y + h - 1}}
+	}
+
+	for _, i := range order {
+		var links []int
+		for _, j := range adj[i] {
+			if placed[j] {
+				links = append(links, j)
+			}
+		}
+
+		best, bestScore := model.Room{}, int32(-1)
+		for range graphCandidates {
+			if err := g.budget.tick(); err != nil {
+				return nil, err
+			}
+			var room model.Room
+			if len(links) == 0 {
+				w, h := sizes[i][0], sizes[i][1]
+				if w+4 > grid.Width() || h+4 > grid.Height() {
+					return nil, nil
+				}
+				x := grid.MinX + 2 + g.rng.Int31n(grid.Width()-w-3)
+				y := grid.MinY + 2 + g.rng.Int31n(grid.Height()-h-3)
+				room = boxAt(i, model.Cell{X: x + w/2, Y: y + h/2})
+			} else {
+				// Step away from a linked room, far enough to clear it and
+				// not so far that the corridor wanders.
+				j := links[g.rng.Intn(len(links))]
+				from := roomCentre(rooms[j])
+				dir := graphDirs[g.rng.Intn(len(graphDirs))]
+				dist := clearance(sizes[i], sizes[j], dir, gap) + g.rng.Int31n(2*gap*int32(slack)/graphLayoutTries+1)
+				room = boxAt(i, model.Cell{
+					X: from.X + scaleDir(dir[0], dist),
+					Y: from.Y + scaleDir(dir[1], dist),
+				})
+			}
+			if !fits(room) {
+				g.emit(RoomRejected{Candidate: room, Reason: RejectOutOfBounds})
+				continue
+			}
+			tooClose := false
+			for j := range rooms {
+				if placed[j] && roomsTooClose(rooms[j], room, gap) {
+					tooClose = true
+					break
+				}
+			}
+			if tooClose {
+				g.emit(RoomRejected{Candidate: room, Reason: RejectNearRoom})
+				continue
+			}
+			var score int32
+			for _, j := range links {
+				score += manhattan(roomCentre(room), roomCentre(rooms[j]))
+			}
+			if bestScore < 0 || score < bestScore {
+				best, bestScore = room, score
+			}
+		}
+		if bestScore < 0 {
+			return nil, nil
+		}
+		rooms[i], placed[i] = best, true
+	}
+	return rooms, nil
+}
+
+// GraphConnector carves one corridor for every edge of the graph laid out
+// by GraphPlacer, from a door of one room to a door of the other, each on a
+// straight stretch of the room's wall facing the other room. Doors are
+// shared only when a room has no cell left Config.Doors.Spacing from its
+// doors. A corridor from the nearest grid edge to the entrance sets the
+// only start. Corridors keep Config.CorridorBuff from rooms except at doors
+// and are not repaired; an edge without a route is reported in an error
+// wrapping ErrUnreachableRoom. Without a laid out graph, for instance after
+// another placer, it behaves as CorridorConnector.
+type GraphConnector struct{}
+
+// Connect implements Connector.
+func (GraphConnector) Connect(s *State, d *model.Dungeon) error {
+	if s.graph == nil || len(s.graph.Nodes) != len(d.Rooms) {
+		return CorridorConnector{}.Connect(s, d)
+	}
+	graph := *s.graph
+	idx := graph.index()
+	pairs := make([][2]int, len(graph.Edges))
+	for k, e := range graph.Edges {
+		pairs[k] = [2]int{idx[e.From], idx[e.To]}
+	}
+
+	var unreachable []error
+	failed, err := s.g.joinPairs(d, pairs, func(k int, doors [2]model.Cell) {
+		s.Emit(EdgeJoined{Edge: k, From: pairs[k][0], To: pairs[k][1], Kind: graph.Edges[k].Door, Doors: doors})
+	})
+	for _, k := range failed {
+		if k < 0 {
+			unreachable = append(unreachable, fmt.Errorf("entrance %q: %w", graph.Nodes[0].Name, ErrUnreachableRoom))
+		} else {
+			e := graph.Edges[k]
+			unreachable = append(unreachable, fmt.Errorf("edge %q to %q: %w", e.From, e.To, ErrUnreachableRoom))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return errors.Join(unreachable...)
+}
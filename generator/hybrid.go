@@ -0,0 +1,273 @@
+package generator
+
+import (
+	"slices"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// defaultCaveRatio is the share of open floor ModeHybrid leaves as cave
+// when CaveConfig.Ratio is zero.
+const defaultCaveRatio = 0.7
+
+func (c CaveConfig) ratio() float64 {
+	if c.Ratio > 0 {
+		return c.Ratio
+	}
+	return defaultCaveRatio
+}
+
+// HybridPipeline grows caves and builds rooms into them with HybridPlacer,
+// joins the caves with CaveConnector and walls them in with CaveWalls.
+func HybridPipeline() Pipeline {
+	return NewPipeline(HybridPlacer{}, CaveConnector{}, CaveWalls{})
+}
+
+// HybridPlacer grows caves as CavePlacer does and then builds rooms of the
+// configured shapes into and next to them, until built floor makes up
+// 1-Config.Cave.Ratio of the open floor or Config.MaxRooms rooms stand.
+//
+// A built room's walls fill the rest of its bounding box and a one tile
+// ring around it, replacing rock and cave floor alike, and doors on the
+// sides of the box open through the ring onto cave floor, as many as
+// Config.Doors gives the shape and kept Config.Doors.Spacing apart. Rooms
+// that would cut a cave in two, or have no side facing cave floor, are
+// turned down; rooms are kept Config.MinRoomGap apart as in Rooms. Built rooms follow the caves
+// in d.Rooms and are drawn in full here, so the wall stage only has to wall
+// in the caves.
+type HybridPlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (HybridPlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	if len(s.Config.RoomShapes) == 0 {
+		return ErrNoShapes
+	}
+	if err := (CavePlacer{}).PlaceRooms(s, d); err != nil {
+		return err
+	}
+	return s.g.buildRooms(s, d)
+}
+
+// buildRooms adds built rooms to the caves already drawn on d and drops
+// the cells they cover from s.caves.
+func (g *Generator) buildRooms(s *State, d *model.Dungeon) error {
+	grid := g.cfg.Grid
+	// cave marks the cave floor left; kept marks doorways later rooms must
+	// not cover. Any other tile that is not rock belongs to a built room.
+	cave := make([]bool, len(d.Tiles))
+	kept := make([]bool, len(d.Tiles))
+	caveFloor := 0
+	for i, t := range d.Tiles {
+		if t == model.TileRoomFloor {
+			cave[i] = true
+			caveFloor++
+		}
+	}
+	isCave := func(c model.Cell) bool {
+		i, ok := grid.Index(c)
+		return ok && cave[i]
+	}
+	regions := g.countRegions(cave)
+
+	share := 1 - g.cfg.Cave.ratio()
+	gap := g.minRoomGap()
+	spacing := g.cfg.Doors.spacing()
+	var built []model.Room
+	builtFloor := 0
+	for len(built) < g.cfg.MaxRooms && float64(builtFloor) < share*float64(caveFloor+builtFloor) {
+		success := false
+		for range 200 {
+			if err := g.budget.tick(); err != nil {
+				return err
+			}
+			room, err := g.RandomRoom()
+			if err != nil {
+				return err
+			}
+			// Leave room for the wall ring inside the grid edge.
+			if !grid.RoomInBoundsWithPadding(room.TopLeft, 2) || !grid.RoomInBoundsWithPadding(room.BottomRight, 2) {
+				g.emit(RoomRejected{Candidate: room, Reason: RejectOutOfBounds})
+				continue
+			}
+			tooClose := false
+			for _, existing := range built {
+				if roomsTooClose(existing, room, gap) {
+					tooClose = true
+					break
+				}
+			}
+			eachBoxCell(room, 1, func(c model.Cell) {
+				i, _ := grid.Index(c)
+				tooClose = tooClose || kept[i] || (!cave[i] && d.Tiles[i] != model.TileEmpty)
+			})
+			if tooClose {
+				g.emit(RoomRejected{Candidate: room, Reason: RejectNearRoom})
+				continue
+			}
+
+			cells, doors := g.doorways(room)
+			open := doors[:0]
+			for _, dw := range doors {
+				if isCave(dw.exit()) {
+					open = append(open, dw)
+				}
+			}
+			if len(open) == 0 {
+				g.emit(RoomRejected{Candidate: room, Reason: RejectNoCaveDoor})
+				continue
+			}
+
+			// Take the room out of the cave and put it back if that splits
+			// or swallows a region.
+			var covered []int32
+			eachBoxCell(room, 1, func(c model.Cell) {
+				if i, _ := grid.Index(c); cave[i] {
+					cave[i] = false
+					covered = append(covered, i)
+				}
+			})
+			if len(covered) > 0 && g.countRegions(cave) != regions {
+				for _, i := range covered {
+					cave[i] = true
+				}
+				g.emit(RoomRejected{Candidate: room, Reason: RejectCaveSplit})
+				continue
+			}
+			caveFloor -= len(covered)
+
+			n := len(d.Rooms)
+			eachBoxCell(room, 1, func(c model.Cell) {
+				if !cells[c] {
+					d.Set(c, model.TileWall)
+					g.emit(WallAdded{Room: n, Cell: c})
+				}
+			})
+			for c := range cells {
+				d.Set(c, model.TileRoomFloor)
+			}
+			for want := g.doorCount(room.Shape); len(room.Doors) < want && len(open) > 0; {
+				k := g.rng.Intn(len(open))
+				dw := open[k]
+				open = slices.Delete(open, k, k+1)
+				if !spaced(dw.door, room.Doors, spacing) {
+					continue
+				}
+				d.Set(dw.door, model.TileDoor)
+				d.Set(dw.passage(), model.TileCorridor)
+				if i, ok := grid.Index(dw.exit()); ok {
+					kept[i] = true
+				}
+				room.Doors = append(room.Doors, dw.door)
+			}
+
+			d.Rooms = append(d.Rooms, room)
+			built = append(built, room)
+			builtFloor += len(cells)
+			g.emit(RoomPlaced{Room: n, Placed: room})
+			for _, c := range room.Doors {
+				g.emit(DoorChosen{Room: n, Door: c})
+			}
+			g.record(StepRoomPlaced, d)
+			success = true
+			break
+		}
+		if !success {
+			break
+		}
+	}
+
+	for k, region := range s.caves {
+		s.caves[k] = slices.DeleteFunc(region, func(c model.Cell) bool { return !isCave(c) })
+	}
+	return nil
+}
+
+// countRegions returns the number of 4-connected regions of open cells.
+func (g *Generator) countRegions(open []bool) int {
+	grid := g.cfg.Grid
+	seen := make([]bool, len(open))
+	var stack []model.Cell
+	n := 0
+	g.eachCell(func(c model.Cell) {
+		i, _ := grid.Index(c)
+		if !open[i] || seen[i] {
+			return
+		}
+		n++
+		seen[i] = true
+		stack = append(stack[:0], c)
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, di := range dirs4 {
+				nc := model.Cell{X: c.X + di.X, Y: c.Y + di.Y}
+				if j, ok := grid.Index(nc); ok && open[j] && !seen[j] {
+					seen[j] = true
+					stack = append(stack, nc)
+				}
+			}
+		}
+	})
+	return n
+}
+
+// doorway is a door cell of a built room and the direction it opens in.
+type doorway struct {
+	door, dir model.Cell
+}
+
+// passage is the wall ring cell in front of the door.
+func (dw doorway) passage() model.Cell {
+	return model.Cell{X: dw.door.X + dw.dir.X, Y: dw.door.Y + dw.dir.Y}
+}
+
+// exit is the cell beyond the wall ring that the door leads to.
+func (dw doorway) exit() model.Cell {
+	return model.Cell{X: dw.door.X + 2*dw.dir.X, Y: dw.door.Y + 2*dw.dir.Y}
+}
+
+// doorways returns the cells of room's shape and every place a door may
+// go: a shape cell on a side of the bounding box, away from its corners,
+// opening outwards across that side, with both neighbours along the side
+// in the shape too, so no door sits on a corner of the shape such as a
+// triangle's apex. Doors are in row order so the pick only depends on the
+// seed.
+func (g *Generator) doorways(room model.Room) (map[model.Cell]bool, []doorway) {
+	cells := make(map[model.Cell]bool)
+	var order []model.Cell
+	g.ForEachRoomCell(room, func(c model.Cell) {
+		if !cells[c] {
+			cells[c] = true
+			order = append(order, c)
+		}
+	})
+
+	var doors []doorway
+	for _, c := range order {
+		onX := c.X == room.TopLeft.X || c.X == room.BottomRight.X
+		onY := c.Y == room.TopLeft.Y || c.Y == room.BottomRight.Y
+		if onX == onY {
+			// A corner, or inside the box.
+			continue
+		}
+		for _, di := range dirs4 {
+			if n := (model.Cell{X: c.X + di.X, Y: c.Y + di.Y}); room.Contains(n) {
+				continue
+			}
+			if cells[model.Cell{X: c.X + di.Y, Y: c.Y + di.X}] && cells[model.Cell{X: c.X - di.Y, Y: c.Y - di.X}] {
+				doors = append(doors, doorway{door: c, dir: di})
+			}
+		}
+	}
+	return cells, doors
+}
+
+// eachBoxCell calls fn for every cell of room's bounding box grown by pad
+// on every side.
+func eachBoxCell(room model.Room, pad int32, fn func(model.Cell)) {
+	for y := room.TopLeft.Y - pad; y <= room.BottomRight.Y+pad; y++ {
+		for x := room.TopLeft.X - pad; x <= room.BottomRight.X+pad; x++ {
+			fn(model.Cell{X: x, Y: y})
+		}
+	}
+}
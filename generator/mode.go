@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode selects the pipeline Generate runs when none was set with
+// UsePipeline.
+type Mode int
+
+const (
+	// ModeRooms places rooms by random sampling and joins them to one
+	// corridor network; see DefaultPipeline.
+	ModeRooms Mode = iota
+	// ModeBSP splits the grid by binary space partitioning, places one
+	// room per leaf and connects sibling partitions; see BSPPipeline.
+	ModeBSP
+	// ModeCave grows caverns with a cellular automaton; see CavePipeline.
+	ModeCave
+	// ModeHybrid grows caverns and builds rooms into them; see
+	// HybridPipeline.
+	ModeHybrid
+	// ModeWalk carves tunnels with random walkers; see WalkPipeline.
+	ModeWalk
+	// ModeWFC fills the grid by Wave Function Collapse on a sample map;
+	// see WFCPipeline.
+	ModeWFC
+	// ModeGraph lays out an authored room graph; see GraphPipeline.
+	ModeGraph
+	// ModeMST places rooms by random sampling and joins them along a
+	// spanning tree with some loops; see MSTPipeline.
+	ModeMST
+)
+
+var modeName = map[Mode]string{
+	ModeRooms:  "rooms",
+	ModeBSP:    "bsp",
+	ModeCave:   "cave",
+	ModeHybrid: "hybrid",
+	ModeWalk:   "walk",
+	ModeWFC:    "wfc",
+	ModeGraph:  "graph",
+	ModeMST:    "mst",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeName[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Mode) MarshalText() ([]byte, error) {
+	name, ok := modeName[m]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode %d", int(m))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Mode) UnmarshalText(b []byte) error {
+	for id, name := range modeName {
+		if strings.EqualFold(name, string(b)) {
+			*m = id
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mode %q", string(b))
+}
+
+// ModeNames returns the names of all modes in Mode order.
+func ModeNames() []string {
+	names := make([]string, len(modeName))
+	for m, name := range modeName {
+		names[m] = name
+	}
+	return names
+}
+
+// usesShapes reports whether m draws rooms from Config.RoomShapes.
+func (m Mode) usesShapes() bool {
+	switch m {
+	case ModeRooms, ModeBSP, ModeHybrid, ModeMST:
+		return true
+	}
+	return false
+}
+
+// Pipeline returns the pipeline for m, or nil stages for an unknown mode.
+func (m Mode) Pipeline() Pipeline {
+	switch m {
+	case ModeRooms:
+		return DefaultPipeline()
+	case ModeBSP:
+		return BSPPipeline()
+	case ModeCave:
+		return CavePipeline()
+	case ModeHybrid:
+		return HybridPipeline()
+	case ModeWalk:
+		return WalkPipeline()
+	case ModeWFC:
+		return WFCPipeline()
+	case ModeGraph:
+		return GraphPipeline()
+	case ModeMST:
+		return MSTPipeline()
+	}
+	return Pipeline{}
+}
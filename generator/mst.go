@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// MSTPipeline places rooms as DefaultPipeline does, joins them with
+// MSTConnector and walls them in.
+func MSTPipeline() Pipeline {
+	return NewPipeline(RandomPlacer{}, MSTConnector{}, RoomWalls{})
+}
+
+// MSTConnector joins the rooms along the minimum spanning tree of the
+// Delaunay triangulation of their centres, so every room is reached by the
+// shortest total length of straight links, and then adds back
+// Config.Loops of the other triangulation edges, picked at random, as
+// loops. Each link becomes a corridor between a door on each room, on a
+// straight stretch of wall facing the other room, and a corridor from the
+// nearest grid edge to room 0 sets the only start. Corridors keep
+// Config.CorridorBuff from rooms except at doors and are not repaired; a
+// link without a route is reported in an error wrapping ErrUnreachableRoom.
+type MSTConnector struct{}
+
+// Connect implements Connector.
+func (MSTConnector) Connect(s *State, d *model.Dungeon) error {
+	g := s.g
+	pairs := g.spanningPairs(d.Rooms)
+	failed, err := g.joinPairs(d, pairs, nil)
+	if err != nil {
+		return err
+	}
+	var unreachable []error
+	for _, k := range failed {
+		if k < 0 {
+			unreachable = append(unreachable, unreachableErr(d.Rooms, 0))
+		} else {
+			unreachable = append(unreachable, fmt.Errorf("corridor from room %d to room %d: %w", pairs[k][0], pairs[k][1], ErrUnreachableRoom))
+		}
+	}
+	return errors.Join(unreachable...)
+}
+
+// spanningPairs returns the rooms to join: the edges of the minimum
+// spanning tree of the Delaunay triangulation of the room centres,
+// shortest first, followed by Config.Loops of the other edges.
+func (g *Generator) spanningPairs(rooms []model.Room) [][2]int {
+	pts := make([][2]int64, len(rooms))
+	for i, r := range rooms {
+		c := roomCentre(r)
+		pts[i] = [2]int64{int64(c.X), int64(c.Y)}
+	}
+	dist := func(e [2]int) int64 {
+		dx, dy := pts[e[0]][0]-pts[e[1]][0], pts[e[0]][1]-pts[e[1]][1]
+		return dx*dx + dy*dy
+	}
+
+	edges := delaunay(pts)
+	if !spans(len(pts), edges) {
+		// Collinear or repeated centres leave the triangulation short of
+		// some rooms; any pair will do then.
+		edges = edges[:0]
+		for i := range pts {
+			for j := i + 1; j < len(pts); j++ {
+				edges = append(edges, [2]int{i, j})
+			}
+		}
+	}
+	slices.SortStableFunc(edges, func(a, b [2]int) int { return cmp.Compare(dist(a), dist(b)) })
+
+	parent := make([]int, len(pts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	var tree, rest [][2]int
+	for _, e := range edges {
+		if a, b := find(e[0]), find(e[1]); a != b {
+			parent[a] = b
+			tree = append(tree, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+
+	n := int(math.Round(g.cfg.Loops * float64(len(rest))))
+	picked := g.rng.Perm(len(rest))[:n]
+	slices.Sort(picked)
+	for _, k := range picked {
+		tree = append(tree, rest[k])
+	}
+	return tree
+}
+
+// spans reports whether edges connect all n points.
+func spans(n int, edges [][2]int) bool {
+	adj := make([][]int, n)
+	for _, e := range edges {
+		adj[e[0]] = append(adj[e[0]], e[1])
+		adj[e[1]] = append(adj[e[1]], e[0])
+	}
+	seen := make([]bool, n)
+	stack := []int{0}
+	count := 0
+	for len(stack) > 0 && n > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		count++
+		stack = append(stack, adj[i]...)
+	}
+	return count == n
+}
+
+// delaunay returns the edges of the Delaunay triangulation of pts, by
+// Bowyer-Watson, as index pairs with the smaller index first, in the
+// order they were found. Integer arithmetic keeps the result the same on
+// every platform.
+func delaunay(pts [][2]int64) [][2]int {
+	n := len(pts)
+	if n < 2 {
+		return nil
+	}
+	lo, hi := pts[0], pts[0]
+	for _, p := range pts {
+		lo[0], lo[1] = min(lo[0], p[0]), min(lo[1], p[1])
+		hi[0], hi[1] = max(hi[0], p[0]), max(hi[1], p[1])
+	}
+	// A triangle far around every point to start from.
+	span := max(hi[0]-lo[0], hi[1]-lo[1]) + 1
+	mx, my := (lo[0]+hi[0])/2, (lo[1]+hi[1])/2
+	all := append(slices.Clip(pts),
+		[2]int64{mx - 20*span, my - span},
+		[2]int64{mx + 20*span, my - span},
+		[2]int64{mx, my + 20*span},
+	)
+
+	tris := [][3]int{{n, n + 1, n + 2}}
+	for p := range n {
+		var bad, keep [][3]int
+		for _, t := range tris {
+			if inCircle(all[t[0]], all[t[1]], all[t[2]], all[p]) {
+				bad = append(bad, t)
+			} else {
+				keep = append(keep, t)
+			}
+		}
+		// The edges of the hole left by the bad triangles are those only
+		// one of them has.
+		shared := make(map[[2]int]int)
+		for _, t := range bad {
+			for k := range 3 {
+				shared[edgeKey(t[k], t[(k+1)%3])]++
+			}
+		}
+		for _, t := range bad {
+			for k := range 3 {
+				if a, b := t[k], t[(k+1)%3]; shared[edgeKey(a, b)] == 1 {
+					keep = append(keep, [3]int{a, b, p})
+				}
+			}
+		}
+		tris = keep
+	}
+
+	seen := make(map[[2]int]bool)
+	var edges [][2]int
+	for _, t := range tris {
+		for k := range 3 {
+			e := edgeKey(t[k], t[(k+1)%3])
+			if e[1] < n && !seen[e] {
+				seen[e] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+	return edges
+}
+
+func edgeKey(a, b int) [2]int {
+	return [2]int{min(a, b), max(a, b)}
+}
+
+// inCircle reports whether p lies strictly inside the circle through the
+// counter-clockwise triangle a, b, c.
+func inCircle(a, b, c, p [2]int64) bool {
+	ax, ay := a[0]-p[0], a[1]-p[1]
+	bx, by := b[0]-p[0], b[1]-p[1]
+	cx, cy := c[0]-p[0], c[1]-p[1]
+	// Below 2^13 the determinant fits in an int64.
+	const limit = 1 << 13
+	if max(abs64(ax), abs64(ay), abs64(bx), abs64(by), abs64(cx), abs64(cy)) < limit {
+		a2, b2, c2 := ax*ax+ay*ay, bx*bx+by*by, cx*cx+cy*cy
+		return ax*(by*c2-b2*cy)-ay*(bx*c2-b2*cx)+a2*(bx*cy-by*cx) > 0
+	}
+
+	v := func(x int64) *big.Int { return big.NewInt(x) }
+	mul := func(x, y *big.Int) *big.Int { return new(big.Int).Mul(x, y) }
+	sub := func(x, y *big.Int) *big.Int { return new(big.Int).Sub(x, y) }
+	add := func(x, y *big.Int) *big.Int { return new(big.Int).Add(x, y) }
+	a2 := add(mul(v(ax), v(ax)), mul(v(ay), v(ay)))
+	b2 := add(mul(v(bx), v(bx)), mul(v(by), v(by)))
+	c2 := add(mul(v(cx), v(cx)), mul(v(cy), v(cy)))
+	det := sub(mul(v(ax), sub(mul(v(by), c2), mul(b2, v(cy)))), mul(v(ay), sub(mul(v(bx), c2), mul(b2, v(cx)))))
+	det = add(det, mul(a2, sub(mul(v(bx), v(cy)), mul(v(by), v(cx)))))
+	return det.Sign() > 0
+}
+
+func abs64(x int64) int64 {
+	return max(x, -x)
+}
@@ -1,200 +1,514 @@
 package generator
 
-import "github.com/mikegio27/proc-dungeons/model"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// pathPlan holds the room footprints, doors and corridor network built by
+// GenPaths, so the connectivity pass can retry rooms with the same state.
+type pathPlan struct {
+	rooms []model.Room
+	// cells and edges are each room's footprint and edge cells in visit
+	// order; map iteration order is random and must never feed the RNG.
+	cells   [][]model.Cell
+	edges   [][]model.Cell
+	doors   []model.Cell
+	hasDoor []bool
+	// wanted holds each room's doors after the first until
+	// openExtraDoors tries them, and extra the ones it opened.
+	wanted [][]extraDoor
+	extra  [][]model.Cell
+
+	// solid is every room cell except the doors.
+	solid   map[model.Cell]bool
+	blocked map[model.Cell]bool
+
+	// corridors is tracked as an ordered list so picking a branch point is
+	// reproducible for a given seed.
+	corridors     map[model.Cell]bool
+	corridorCells []model.Cell
+	starts        []model.Cell
+}
+
+// extraDoor is a door planned after a room's first, facing room face, or
+// the grid edge when face is -1.
+type extraDoor struct {
+	cell model.Cell
+	face int
+}
+
+// roomDoors returns the doors of room i, first door first.
+func (p *pathPlan) roomDoors(i int) []model.Cell {
+	var doors []model.Cell
+	if p.hasDoor[i] {
+		doors = append(doors, p.doors[i])
+	}
+	return append(doors, p.extra[i]...)
+}
+
+func (p *pathPlan) addCorridor(c model.Cell) {
+	if !p.corridors[c] {
+		p.corridors[c] = true
+		p.corridorCells = append(p.corridorCells, c)
+	}
+}
 
 // GenPaths connects every room to a single corridor network.
-// - One door per room (edge cell)
+// - Config.Doors sets the doors per room, on straight stretches of wall,
+// facing the nearest rooms and spaced apart
 // - First corridor starts at perimeter
-// - Subsequent rooms connect from existing corridor cell
+// - Subsequent rooms connect their first door from existing corridor cell
+// - Further doors connect to the first door of the room they face
 // - Corridors keep distance from rooms via CorridorBuff (except at doors)
 // - CorridorW controls thickness
-func (g *Generator) GenPaths(d *model.Dungeon, rooms []model.Room) []model.Cell {
-	// ---- 1) Room footprints + doors ----
+//
+// The doors that were opened are stored in each room's Doors. Rooms that
+// cannot be reached are left unconnected and reported in an error
+// wrapping ErrUnreachableRoom, alongside the starts that were made.
+func (g *Generator) GenPaths(d *model.Dungeon, rooms []model.Room) ([]model.Cell, error) {
+	p, err := g.genPaths(d, rooms)
+	if err != nil && !errors.Is(err, ErrUnreachableRoom) {
+		return p.starts, err
+	}
+	if err := g.openExtraDoors(d, p, nil); err != nil {
+		return p.starts, err
+	}
+	for i := range rooms {
+		rooms[i].Doors = p.roomDoors(i)
+	}
+	return p.starts, err
+}
 
-	roomCells := make(map[model.Cell]bool) // interior (excluding door)
-	roomEdges := make(map[model.Cell]bool) // edge ring (excluding door)
+func (g *Generator) genPaths(d *model.Dungeon, rooms []model.Room) (*pathPlan, error) {
+	p := g.planPaths(d, rooms)
 
-	roomDoors := make([]model.Cell, len(rooms))
-	roomHasDoor := make([]bool, len(rooms))
+	var unreachable []error
+	for i := range rooms {
+		if !p.hasDoor[i] {
+			continue
+		}
+		ok, err := g.connectRoom(d, p, i)
+		if err != nil {
+			return p, err
+		}
+		if !ok {
+			unreachable = append(unreachable, unreachableErr(rooms, i))
+		}
+	}
+	return p, errors.Join(unreachable...)
+}
+
+func unreachableErr(rooms []model.Room, i int) error {
+	return fmt.Errorf("room %d (%s at %v): %w", i, rooms[i].Shape, rooms[i].TopLeft, ErrUnreachableRoom)
+}
+
+// planPaths computes room footprints, picks the doors of every room and
+// builds the blocked map that keeps corridors away from rooms. Only the
+// first door of each room is opened; the rest wait in wanted.
+func (g *Generator) planPaths(d *model.Dungeon, rooms []model.Room) *pathPlan {
+	p := &pathPlan{
+		rooms:     rooms,
+		cells:     make([][]model.Cell, len(rooms)),
+		edges:     make([][]model.Cell, len(rooms)),
+		doors:     make([]model.Cell, len(rooms)),
+		hasDoor:   make([]bool, len(rooms)),
+		wanted:    make([][]extraDoor, len(rooms)),
+		extra:     make([][]model.Cell, len(rooms)),
+		corridors: make(map[model.Cell]bool),
+	}
+
+	// ---- 1) Room footprints + doors ----
 
 	for i, room := range rooms {
-		local := make(map[model.Cell]bool)
-		g.ForEachRoomCell(room, func(c model.Cell) { local[c] = true })
-
-		// edge cells: any cell with a neighbor not in local
-		var edgeCells []model.Cell
-		for c := range local {
-			neighbors := []model.Cell{
-				{X: c.X + 1, Y: c.Y},
-				{X: c.X - 1, Y: c.Y},
-				{X: c.X, Y: c.Y + 1},
-				{X: c.X, Y: c.Y - 1},
+		p.cells[i], p.edges[i] = g.footprint(room)
+
+		// choose the doors
+		if edgeCells := p.edges[i]; len(edgeCells) > 0 {
+			doors, faces := g.pickDoors(rooms, i, g.doorSites(p.cells[i], edgeCells), g.doorCount(room.Shape))
+			for k := 1; k < len(doors); k++ {
+				p.wanted[i] = append(p.wanted[i], extraDoor{cell: doors[k], face: faces[k]})
 			}
-			for _, n := range neighbors {
-				if !local[n] {
-					edgeCells = append(edgeCells, c)
-					break
-				}
+			door := doors[0]
+			p.doors[i] = door
+			p.hasDoor[i] = true
+			d.Set(door, model.TileDoor)
+			g.emit(DoorChosen{Room: i, Door: door})
+			g.record(StepDoorChosen, d)
+		}
+	}
+
+	// ---- 2) Build ONE blocked map (rooms + edge ring + buffer) ----
+
+	p.solid = make(map[model.Cell]bool)
+	for i := range rooms {
+		for _, c := range p.cells[i] {
+			if p.hasDoor[i] && c == p.doors[i] {
+				continue
 			}
+			p.solid[c] = true
 		}
+	}
+	p.blocked = g.blockedMap(p, g.cfg.CorridorBuff)
+	return p
+}
 
-		// choose a door
-		if len(edgeCells) > 0 {
-			// ensure door is not on the edge of the dungeon grid
-			var validEdgeCells []model.Cell
-			for _, c := range edgeCells {
-				if c.X > g.cfg.Grid.MinX && c.X < g.cfg.Grid.MaxX &&
-					c.Y > g.cfg.Grid.MinY && c.Y < g.cfg.Grid.MaxY {
-					validEdgeCells = append(validEdgeCells, c)
-				}
+// footprint returns the cells of room and those of them on its edge, that
+// is with a neighbour outside the room, each in visit order.
+func (g *Generator) footprint(room model.Room) (cells, edges []model.Cell) {
+	local := make(map[model.Cell]bool)
+	g.ForEachRoomCell(room, func(c model.Cell) {
+		if !local[c] {
+			local[c] = true
+			cells = append(cells, c)
+		}
+	})
+
+	// edge cells: any cell with a neighbor not in local
+	for _, c := range cells {
+		neighbors := []model.Cell{
+			{X: c.X + 1, Y: c.Y},
+			{X: c.X - 1, Y: c.Y},
+			{X: c.X, Y: c.Y + 1},
+			{X: c.X, Y: c.Y - 1},
+		}
+		for _, n := range neighbors {
+			if !local[n] {
+				edges = append(edges, c)
+				break
+			}
+		}
+	}
+	return cells, edges
+}
+
+// joinPairs gives rooms[a] and rooms[b] of every pair a door on a straight
+// stretch of wall, as GenPaths picks them, opening toward the other room
+// and nearest to it, and carves a corridor between the two doors. Doors
+// keep Config.Doors.Spacing apart and are shared only when a room has no
+// cell left that far from its doors. First a corridor runs from the
+// nearest grid edge to a door of room 0, which sets the only start.
+// Corridors keep CorridorBuff from rooms except at doors. Each room's doors
+// are stored in its Doors. joined, when not nil, is called with the doors
+// of each pair once its corridor is carved. joinPairs returns the pairs it
+// found no route for, with -1 for the entrance; the error is only set when
+// the budget runs out.
+func (g *Generator) joinPairs(d *model.Dungeon, pairs [][2]int, joined func(k int, doors [2]model.Cell)) ([]int, error) {
+	if len(d.Rooms) == 0 {
+		return nil, nil
+	}
+	cells := make([][]model.Cell, len(d.Rooms))
+	sites := make([][]doorSite, len(d.Rooms))
+	for i, r := range d.Rooms {
+		var edges []model.Cell
+		cells[i], edges = g.footprint(r)
+		sites[i] = g.doorSites(cells[i], edges)
+	}
+	spacing := g.cfg.Doors.spacing()
+	roomDoors := make([][]model.Cell, len(d.Rooms))
+	isDoor := make(map[model.Cell]bool)
+	var doors []model.Cell
+	door := func(i int, toward model.Cell) model.Cell {
+		s, ok := bestSite(sites[i], toward, func(s doorSite) bool {
+			return !isDoor[s.cell] && spaced(s.cell, roomDoors[i], spacing)
+		})
+		if !ok {
+			s, _ = bestSite(sites[i], toward, func(s doorSite) bool { return isDoor[s.cell] })
+		}
+		if !isDoor[s.cell] {
+			isDoor[s.cell] = true
+			doors = append(doors, s.cell)
+			roomDoors[i] = append(roomDoors[i], s.cell)
+			d.Set(s.cell, model.TileDoor)
+		}
+		g.emit(DoorChosen{Room: i, Door: s.cell})
+		return s.cell
+	}
+
+	grid := g.cfg.Grid
+	edgeDist := func(c model.Cell) int32 {
+		return min(c.X-grid.MinX, grid.MaxX-c.X, c.Y-grid.MinY, grid.MaxY-c.Y)
+	}
+	nearest := sites[0][0].cell
+	for _, s := range sites[0] {
+		if edgeDist(s.cell) < edgeDist(nearest) {
+			nearest = s.cell
+		}
+	}
+	entrance := door(0, g.nearestEdgeCell(nearest))
+	ends := make([][2]model.Cell, len(pairs))
+	for k, pr := range pairs {
+		a, b := pr[0], pr[1]
+		ends[k] = [2]model.Cell{door(a, roomCentre(d.Rooms[b])), door(b, roomCentre(d.Rooms[a]))}
+	}
+	for i := range d.Rooms {
+		d.Rooms[i].Doors = roomDoors[i]
+	}
+	g.record(StepDoorChosen, d)
+
+	solid := make(map[model.Cell]bool)
+	for i := range d.Rooms {
+		for _, c := range cells[i] {
+			if !isDoor[c] {
+				solid[c] = true
 			}
-			if len(validEdgeCells) == 0 {
-				// fallback to any edge cell
-				validEdgeCells = edgeCells
+		}
+	}
+	blocked := g.blockedAround(solid, doors, g.cfg.CorridorBuff)
+	carve := func(i int, from model.Cell, path []model.Cell) {
+		g.emit(PathFound{Room: i, From: from, Door: path[len(path)-1], Length: len(path)})
+		for _, c := range path {
+			if !isDoor[c] {
+				g.carveCorridor(d, c, blocked)
 			}
-			door := validEdgeCells[g.rng.Intn(len(validEdgeCells))]
-			roomDoors[i] = door
-			roomHasDoor[i] = true
-			d.Set(door, model.TileDoor)
+		}
+		g.emit(CorridorCarved{Room: i, Path: path})
+		g.record(StepCorridorCarved, d)
+	}
 
-			// add to global maps, skipping door
-			for c := range local {
-				if c == door {
-					continue
-				}
-				roomCells[c] = true
+	var failed []int
+	path, src, err := g.findPathFrom(g.freeEdgeCells(blocked), entrance, blocked)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0 {
+		g.carveCorridor(d, src, blocked)
+		d.Starts = []model.Cell{src}
+		carve(0, src, path)
+	} else {
+		g.emit(PathFailed{Room: 0, Door: entrance})
+		failed = append(failed, -1)
+	}
+
+	for k, pr := range pairs {
+		from, to := ends[k][0], ends[k][1]
+		path, err := g.findPath(from, to, blocked)
+		if err != nil {
+			return failed, err
+		}
+		if len(path) == 0 {
+			g.emit(PathFailed{Room: pr[1], Door: to})
+			failed = append(failed, k)
+			continue
+		}
+		carve(pr[1], from, path)
+		if joined != nil {
+			joined(k, ends[k])
+		}
+	}
+	return failed, nil
+}
+
+// openExtraDoors opens the wanted doors of every room not skipped, each
+// with a corridor from the first door of the room it faces. Doors facing
+// the grid edge, a skipped room or one without a door, or a room already
+// joined to theirs this way, and doors without a route, stay closed. The
+// error is only set when the budget runs out.
+func (g *Generator) openExtraDoors(d *model.Dungeon, p *pathPlan, skip []bool) error {
+	joined := make(map[[2]int]bool)
+	for i, wanted := range p.wanted {
+		p.wanted[i] = nil
+		if skip != nil && skip[i] {
+			continue
+		}
+		for _, w := range wanted {
+			f := w.face
+			if f < 0 || (skip != nil && skip[f]) || !p.hasDoor[f] || joined[edgeKey(i, f)] {
+				continue
 			}
-			for _, c := range edgeCells {
-				if c == door {
-					continue
-				}
-				roomEdges[c] = true
+			p.extra[i] = append(p.extra[i], w.cell)
+			delete(p.solid, w.cell)
+			blocked := g.blockedMap(p, g.cfg.CorridorBuff)
+			path, err := g.findPath(p.doors[f], w.cell, blocked)
+			if err != nil {
+				return err
 			}
-		} else {
-			// degenerate: treat whole thing as roomCells
-			for c := range local {
-				roomCells[c] = true
+			if path == nil {
+				p.extra[i] = p.extra[i][:len(p.extra[i])-1]
+				p.solid[w.cell] = true
+				g.emit(PathFailed{Room: i, Door: w.cell})
+				continue
 			}
+			joined[edgeKey(i, f)] = true
+			p.blocked = blocked
+			d.Set(w.cell, model.TileDoor)
+			g.emit(DoorChosen{Room: i, Door: w.cell})
+			g.record(StepDoorChosen, d)
+			g.emit(PathFound{Room: i, From: p.doors[f], Door: w.cell, Length: len(path)})
+			g.carvePath(d, p, i, path, blocked)
 		}
 	}
+	return nil
+}
 
-	roomSolid := mergeBoolMaps(roomCells, roomEdges)
+// innerCells returns the cells that are not on the edge of the grid.
+func (g *Generator) innerCells(cells []model.Cell) []model.Cell {
+	var inner []model.Cell
+	for _, c := range cells {
+		if c.X > g.cfg.Grid.MinX && c.X < g.cfg.Grid.MaxX &&
+			c.Y > g.cfg.Grid.MinY && c.Y < g.cfg.Grid.MaxY {
+			inner = append(inner, c)
+		}
+	}
+	return inner
+}
 
-	// ---- 2) Build ONE blocked map (rooms + edge ring + buffer) ----
+// blockedMap returns every cell within buff of a room, except the doors and
+// a small approach area around them.
+func (g *Generator) blockedMap(p *pathPlan, buff int32) map[model.Cell]bool {
+	var doors []model.Cell
+	for i := range p.rooms {
+		doors = append(doors, p.roomDoors(i)...)
+	}
+	return g.blockedAround(p.solid, doors, buff)
+}
 
-	// Base blocked: everything within CorridorBuff of rooms/edges.
-	blockedBase := g.expand(roomSolid, g.cfg.CorridorBuff)
-	for c := range roomSolid {
-		blockedBase[c] = true
+// blockedAround returns every cell within buff of solid, except doors and
+// a small approach area around them.
+func (g *Generator) blockedAround(solid map[model.Cell]bool, doors []model.Cell, buff int32) map[model.Cell]bool {
+	// Base blocked: everything within buff of rooms/edges.
+	blocked := g.expand(solid, buff)
+	for c := range solid {
+		blocked[c] = true
 	}
 
 	// Allow doors + a *small* approach area so BFS can actually attach.
 	// If you clear the full buff radius, you basically undo the whole idea.
-	for i := range rooms {
-		if !roomHasDoor[i] {
-			continue
-		}
-		door := roomDoors[i]
+	for _, door := range doors {
 		// Door cell must be allowed
-		delete(blockedBase, door)
+		delete(blocked, door)
 
 		// Also allow a 1-tile halo outside the door so corridors can “plug in”
-		g.clearRadius(blockedBase, door, 1)
+		g.clearRadius(blocked, door, 1)
 	}
+	return blocked
+}
 
-	// ---- 3) Connect rooms into a single corridor network ----
-
-	corridors := make(map[model.Cell]bool)
-	var starts []model.Cell
-
-	for i := range rooms {
-		if !roomHasDoor[i] {
-			continue
+// connectRoom carves a corridor from the network (or the grid edge, for the
+// first room) to room i's door. It reports false if the door is unreachable.
+func (g *Generator) connectRoom(d *model.Dungeon, p *pathPlan, i int) (bool, error) {
+	target := p.doors[i]
+
+	start, ok := g.randomCorridorCell(p.corridorCells)
+	if !ok {
+		// Prefer an edge cell outside the room buffer, so the corridor is
+		// not boxed in before it starts.
+		var err error
+		if start, err = g.edgeStartingCell(p.blocked); errors.Is(err, ErrUnreachableRoom) {
+			start, err = g.edgeStartingCell(p.solid)
 		}
-		target := roomDoors[i]
-
-		var start model.Cell
-		if len(corridors) == 0 {
-			start = g.edgeStartingCell(roomSolid)
-			starts = append(starts, start)
-		} else {
-			if c, ok := g.randomCorridorCell(corridors); ok {
-				start = c
-			} else {
-				start = g.edgeStartingCell(roomSolid)
-				starts = append(starts, start)
-			}
+		if err != nil {
+			return false, err
 		}
+		p.starts = append(p.starts, start)
+		d.Starts = p.starts
+	}
 
-		// carve start
-		if d.At(start) != model.TileDoor {
-			g.carveCorridor(d, start, blockedBase)
-		}
-		corridors[start] = true
+	// carve start
+	if d.At(start) != model.TileDoor {
+		g.carveCorridor(d, start, p.blocked)
+	}
+	p.addCorridor(start)
 
-		path, ok := g.findPath(start, target, blockedBase)
-		if !ok {
+	path, err := g.findPath(start, target, p.blocked)
+	if err != nil {
+		return false, err
+	}
+	if path == nil {
+		g.emit(PathFailed{Room: i, Door: target})
+		return false, nil
+	}
+	g.emit(PathFound{Room: i, From: start, Door: target, Length: len(path)})
+	g.carvePath(d, p, i, path, p.blocked)
+	return true, nil
+}
+
+// carvePath carves every cell of path to room i's door, except doors, into
+// the network.
+func (g *Generator) carvePath(d *model.Dungeon, p *pathPlan, i int, path []model.Cell, blocked map[model.Cell]bool) {
+	for _, c := range path {
+		if d.At(c) == model.TileDoor {
 			continue
 		}
+		g.carveCorridor(d, c, blocked)
+		p.addCorridor(c)
+	}
+	g.emit(CorridorCarved{Room: i, Path: path})
+	g.record(StepCorridorCarved, d)
+}
 
-		for _, c := range path {
-			if d.At(c) == model.TileDoor {
-				continue
-			}
-			g.carveCorridor(d, c, blockedBase)
-			corridors[c] = true
+// edgeStartingCell returns a random cell on the perimeter that is not inside
+// roomSolid (or any other set of cells to avoid). It returns an error wrapping ErrUnreachableRoom when every
+// perimeter cell is taken.
+func (g *Generator) edgeStartingCell(roomSolid map[model.Cell]bool) (model.Cell, error) {
+	perimeter := g.perimeter()
+	if perimeter <= 0 {
+		return model.Cell{X: g.cfg.Grid.MinX, Y: g.cfg.Grid.MinY}, nil
+	}
+
+	// Random probes find a free cell quickly on any sensible layout.
+	for range 4 * perimeter {
+		if err := g.budget.tick(); err != nil {
+			return model.Cell{}, err
+		}
+		if c := g.perimeterCell(g.rng.Int31n(perimeter)); !roomSolid[c] {
+			return c, nil
 		}
 	}
 
-	return starts
+	// Rooms cover most of the edge: pick from the free cells directly, or
+	// give up if there are none instead of probing forever.
+	free := g.freeEdgeCells(roomSolid)
+	if len(free) == 0 {
+		return model.Cell{}, fmt.Errorf("no free cell on the grid edge to start a corridor: %w", ErrUnreachableRoom)
+	}
+	return free[g.rng.Intn(len(free))], nil
 }
 
-// edgeStartingCell returns a random cell on the perimeter that is not inside roomSolid.
-func (g *Generator) edgeStartingCell(roomSolid map[model.Cell]bool) model.Cell {
+// perimeter returns the number of cells on the edge of the grid.
+func (g *Generator) perimeter() int32 {
 	plane := g.cfg.Grid
-	width := plane.MaxX - plane.MinX + 1
-	height := plane.MaxY - plane.MinY + 1
-	perimeter := 2*(width+height) - 4
-	if perimeter <= 0 {
-		return model.Cell{X: plane.MinX, Y: plane.MinY}
-	}
-
-	for {
-		pos := g.rng.Int31n(perimeter)
-		var x, y int32
+	return 2*(plane.Width()+plane.Height()) - 4
+}
 
-		switch {
-		case pos < width:
-			x = plane.MinX + pos
-			y = plane.MinY
-		case pos < width+height-1:
-			x = plane.MaxX
-			y = plane.MinY + (pos - width + 1)
-		case pos < 2*width+height-2:
-			x = plane.MaxX - (pos - (width + height - 1))
-			y = plane.MaxY
-		default:
-			x = plane.MinX
-			y = plane.MaxY - (pos - (2*width + height - 2) + 1)
-		}
+// perimeterCell maps pos in [0, perimeter) to an edge cell, walking the
+// grid clockwise from its MinX, MinY corner.
+func (g *Generator) perimeterCell(pos int32) model.Cell {
+	plane := g.cfg.Grid
+	width, height := plane.Width(), plane.Height()
+	switch {
+	case pos < width:
+		return model.Cell{X: plane.MinX + pos, Y: plane.MinY}
+	case pos < width+height-1:
+		return model.Cell{X: plane.MaxX, Y: plane.MinY + (pos - width + 1)}
+	case pos < 2*width+height-2:
+		return model.Cell{X: plane.MaxX - (pos - (width + height - 1)), Y: plane.MaxY}
+	default:
+		return model.Cell{X: plane.MinX, Y: plane.MaxY - (pos - (2*width + height - 2) + 1)}
+	}
+}
 
-		c := model.Cell{X: x, Y: y}
-		if !roomSolid[c] {
-			return c
+// freeEdgeCells returns the perimeter cells outside roomSolid in perimeter
+// order.
+func (g *Generator) freeEdgeCells(roomSolid map[model.Cell]bool) []model.Cell {
+	var free []model.Cell
+	for pos := range g.perimeter() {
+		if c := g.perimeterCell(pos); !roomSolid[c] {
+			free = append(free, c)
 		}
 	}
+	return free
 }
 
-// randomCorridorCell picks a random existing corridor cell.
-func (g *Generator) randomCorridorCell(corridors map[model.Cell]bool) (model.Cell, bool) {
+// randomCorridorCell picks a random existing corridor cell. corridors must
+// be in a stable order (insertion order) for the pick to be reproducible.
+func (g *Generator) randomCorridorCell(corridors []model.Cell) (model.Cell, bool) {
 	if len(corridors) == 0 {
 		return model.Cell{}, false
 	}
-	cands := make([]model.Cell, 0, len(corridors))
-	for c := range corridors {
-		cands = append(cands, c)
-	}
-	return cands[g.rng.Intn(len(cands))], true
+	return corridors[g.rng.Intn(len(corridors))], true
 }
 
 // carveCorridor writes corridor tiles around center cell according to CorridorW,
@@ -224,16 +538,36 @@ func (g *Generator) carveCorridor(d *model.Dungeon, center model.Cell, blocked m
 }
 
 // findPath BFS from start to target, avoiding blocked cells.
-// Returns path excluding start (includes target).
-func (g *Generator) findPath(start, target model.Cell, blocked map[model.Cell]bool) ([]model.Cell, bool) {
+// Returns path excluding start (includes target), or nil when the target
+// cannot be reached. The error is only set when the budget runs out.
+func (g *Generator) findPath(start, target model.Cell, blocked map[model.Cell]bool) ([]model.Cell, error) {
+	path, _, err := g.findPathFrom([]model.Cell{start}, target, blocked)
+	return path, err
+}
+
+// findPathFrom is findPath from whichever of sources is closest to target.
+// It also returns the source the path starts from. Ties go to the earliest
+// source, so the result only depends on the order of sources.
+func (g *Generator) findPathFrom(sources []model.Cell, target model.Cell, blocked map[model.Cell]bool) ([]model.Cell, model.Cell, error) {
 	dirs := []model.Cell{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
 
-	queue := []model.Cell{start}
+	queue := make([]model.Cell, 0, len(sources))
 	prev := make(map[model.Cell]model.Cell)
 	seen := make(map[model.Cell]bool)
-	seen[start] = true
+	for _, s := range sources {
+		if s == target {
+			return []model.Cell{}, s, nil
+		}
+		if !seen[s] {
+			seen[s] = true
+			queue = append(queue, s)
+		}
+	}
 
 	for len(queue) > 0 {
+		if err := g.budget.tick(); err != nil {
+			return nil, model.Cell{}, err
+		}
 		c := queue[0]
 		queue = queue[1:]
 
@@ -255,21 +589,28 @@ func (g *Generator) findPath(start, target model.Cell, blocked map[model.Cell]bo
 			prev[nc] = c
 
 			if nc == target {
+				// Sources are the only seen cells without a prev entry.
 				var path []model.Cell
-				for cur := nc; cur != start; cur = prev[cur] {
+				cur := nc
+				for {
+					p, ok := prev[cur]
+					if !ok {
+						break
+					}
 					path = append(path, cur)
+					cur = p
 				}
 				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
 					path[i], path[j] = path[j], path[i]
 				}
-				return path, true
+				return path, cur, nil
 			}
 
 			queue = append(queue, nc)
 		}
 	}
 
-	return nil, false
+	return nil, model.Cell{}, nil
 }
 
 // expand includes all cells within Chebyshev radius r of any input cell.
@@ -304,14 +645,3 @@ func (g *Generator) clearRadius(m map[model.Cell]bool, center model.Cell, r int3
 		}
 	}
 }
-
-func mergeBoolMaps(a, b map[model.Cell]bool) map[model.Cell]bool {
-	out := make(map[model.Cell]bool, len(a)+len(b))
-	for k := range a {
-		out[k] = true
-	}
-	for k := range b {
-		out[k] = true
-	}
-	return out
-}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// State is shared by the stages of one Generate call. Stages draw all
+// randomness from Rand, so a pipeline is as reproducible as its stages.
+type State struct {
+	Config Config
+	Rand   *rand.Rand
+
+	g *Generator
+	// tree is the partition made by BSPPlacer, for BSPConnector.
+	tree *bspNode
+	// caves are the regions kept by CavePlacer, largest first, for
+	// CaveConnector.
+	caves [][]model.Cell
+	// tunnels are the cells carved by WalkPlacer, for WalkConnector.
+	tunnels []model.Cell
+	// graph is the room graph laid out by GraphPlacer, for GraphConnector.
+	graph *RoomGraph
+}
+
+// Tick counts one unit of work against Config.MaxIterations and
+// Config.Timeout. Stages that loop should call it on every iteration and
+// return its error, which is the context error or wraps ErrBudgetExceeded.
+func (s *State) Tick() error {
+	return s.g.budget.tick()
+}
+
+// Emit sends e to the generator's observers.
+func (s *State) Emit(e Event) {
+	s.g.emit(e)
+}
+
+// Record passes d to the generator's Recorder as a frame after step.
+func (s *State) Record(step Step, d *model.Dungeon) {
+	s.g.record(step, d)
+}
+
+// Stage is one step of a Pipeline. A Stage that fails with an error
+// wrapping ErrUnreachableRoom has still left d usable, so the pipeline
+// carries on; any other error stops it.
+type Stage interface {
+	Run(s *State, d *model.Dungeon) error
+}
+
+// StageFunc adapts a function to a Stage.
+type StageFunc func(s *State, d *model.Dungeon) error
+
+// Run calls f(s, d).
+func (f StageFunc) Run(s *State, d *model.Dungeon) error { return f(s, d) }
+
+// RoomPlacer decides where the rooms go, setting d.Rooms. It may also
+// draw tiles, for layouts that are not built from room boxes.
+type RoomPlacer interface {
+	PlaceRooms(s *State, d *model.Dungeon) error
+}
+
+// Connector joins the rooms with corridors and doors and sets d.Starts.
+type Connector interface {
+	Connect(s *State, d *model.Dungeon) error
+}
+
+// WallBuilder draws room floors and the walls around them.
+type WallBuilder interface {
+	BuildWalls(s *State, d *model.Dungeon) error
+}
+
+// Decorator post-processes the finished layout.
+type Decorator interface {
+	Decorate(s *State, d *model.Dungeon) error
+}
+
+type placeStage struct{ RoomPlacer }
+type connectStage struct{ Connector }
+type wallStage struct{ WallBuilder }
+type decorateStage struct{ Decorator }
+
+func (p placeStage) Run(s *State, d *model.Dungeon) error     { return p.PlaceRooms(s, d) }
+func (c connectStage) Run(s *State, d *model.Dungeon) error   { return c.Connect(s, d) }
+func (w wallStage) Run(s *State, d *model.Dungeon) error      { return w.BuildWalls(s, d) }
+func (dc decorateStage) Run(s *State, d *model.Dungeon) error { return dc.Decorate(s, d) }
+
+// Pipeline is the list of stages Generate runs, in order, over an empty
+// dungeon.
+type Pipeline struct {
+	Stages []Stage
+}
+
+// NewPipeline returns the pipeline that places rooms with p, connects
+// them with c, draws walls with w and then runs each decorator. Any of p, c
+// and w may be nil to skip that stage.
+func NewPipeline(p RoomPlacer, c Connector, w WallBuilder, decorators ...Decorator) Pipeline {
+	var out Pipeline
+	if p != nil {
+		out.Stages = append(out.Stages, placeStage{p})
+	}
+	if c != nil {
+		out.Stages = append(out.Stages, connectStage{c})
+	}
+	if w != nil {
+		out.Stages = append(out.Stages, wallStage{w})
+	}
+	for _, dc := range decorators {
+		out.Stages = append(out.Stages, decorateStage{dc})
+	}
+	return out
+}
+
+// DefaultPipeline places rooms by random sampling, connects them with one
+// door each to a single corridor network and walls them in, which is what
+// Generate does unless the generator is given another pipeline.
+func DefaultPipeline() Pipeline {
+	return NewPipeline(RandomPlacer{}, CorridorConnector{}, RoomWalls{})
+}
+
+// Run runs the stages over d in order. Errors wrapping ErrUnreachableRoom
+// are collected and returned together at the end; any other error is
+// returned at once.
+func (p Pipeline) Run(s *State, d *model.Dungeon) error {
+	var unreachable []error
+	for _, st := range p.Stages {
+		err := st.Run(s, d)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrUnreachableRoom):
+			unreachable = append(unreachable, err)
+		default:
+			return err
+		}
+	}
+	return errors.Join(unreachable...)
+}
+
+// BSPPipeline places rooms with BSPPlacer, connects them with
+// BSPConnector and walls them in.
+func BSPPipeline() Pipeline {
+	return NewPipeline(BSPPlacer{}, BSPConnector{}, RoomWalls{})
+}
+
+// UsePipeline makes Generate run p instead of the pipeline for
+// Config.Mode.
+func (g *Generator) UsePipeline(p Pipeline) {
+	g.pipeline = &p
+}
+
+// RandomPlacer places up to Config.MaxRooms rooms with Generator.Rooms. It
+// fails with ErrNoRoomsPlaced when rooms were requested and none fit.
+type RandomPlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (RandomPlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	rooms, err := s.g.Rooms(d.Starts, s.Config.MaxRooms)
+	if err != nil {
+		return err
+	}
+	if len(rooms) == 0 && s.Config.MaxRooms > 0 {
+		return ErrNoRoomsPlaced
+	}
+	d.Rooms = rooms
+	return nil
+}
+
+// CorridorConnector gives every room its doors and carves corridors from
+// the grid edge and then from the growing network to each room, as
+// GenPaths does. It then repairs unreachable rooms according to
+// Config.Repair, which may drop rooms from d.Rooms, and records the
+// outcome for Generator.Connectivity.
+type CorridorConnector struct{}
+
+// Connect implements Connector.
+func (CorridorConnector) Connect(s *State, d *model.Dungeon) error {
+	plan, err := s.g.genPaths(d, d.Rooms)
+	if err != nil && !errors.Is(err, ErrUnreachableRoom) {
+		return err
+	}
+	s.g.report, err = s.g.ensureConnected(d, plan)
+	return err
+}
+
+// RoomWalls fills every room's shape with floor and walls it in, as
+// AddRoomEdges does.
+type RoomWalls struct{}
+
+// BuildWalls implements WallBuilder.
+func (RoomWalls) BuildWalls(s *State, d *model.Dungeon) error {
+	s.g.AddRoomEdges(d, d.Rooms)
+	return nil
+}
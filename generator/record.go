@@ -0,0 +1,59 @@
+package generator
+
+import "github.com/mikegio27/proc-dungeons/model"
+
+// Step identifies the generation step a recorded frame follows.
+type Step int
+
+const (
+	// StepRoomPlaced follows each room accepted by Rooms. The frame has
+	// the rooms placed so far in Rooms and no tiles yet.
+	StepRoomPlaced Step = iota
+	// StepDoorChosen follows each door set, including the doors tried
+	// while repairing unreachable rooms.
+	StepDoorChosen
+	// StepCorridorCarved follows each corridor carved to a door.
+	StepCorridorCarved
+	// StepWallsAdded follows the floor and walls of each room being drawn
+	// by AddRoomEdges.
+	StepWallsAdded
+	// StepCaveSmoothed follows the random fill and each smoothing round of
+	// the cave automaton. The frame shows open cells as room floor.
+	StepCaveSmoothed
+	// StepTunnelCarved follows every 50 cells carved by the random walkers
+	// and the end of each walker's walk.
+	StepTunnelCarved
+)
+
+var stepName = map[Step]string{
+	StepRoomPlaced:     "room-placed",
+	StepDoorChosen:     "door-chosen",
+	StepCorridorCarved: "corridor-carved",
+	StepWallsAdded:     "walls-added",
+	StepCaveSmoothed:   "cave-smoothed",
+	StepTunnelCarved:   "tunnel-carved",
+}
+
+func (s Step) String() string {
+	if name, ok := stepName[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Recorder is called with the dungeon as it stands after each generation
+// step. d is the generator's working copy: it is only valid during the
+// call and must not be modified.
+type Recorder func(step Step, d *model.Dungeon)
+
+// Record makes the generator call r after every step of Generate, Rooms,
+// GenPaths and AddRoomEdges; nil stops recording.
+func (g *Generator) Record(r Recorder) {
+	g.recorder = r
+}
+
+func (g *Generator) record(step Step, d *model.Dungeon) {
+	if g.recorder != nil {
+		g.recorder(step, d)
+	}
+}
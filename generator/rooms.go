@@ -1,71 +1,99 @@
 package generator
 
 import (
-	"fmt"
 	"math"
 
 	"github.com/mikegio27/proc-dungeons/model"
 )
 
-// maxRoomAreaFraction controls the maximum fraction of the total grid area
-// that any single room's bounding box is allowed to occupy.
-const maxRoomAreaFraction = 0.05
+// defaultMaxRoomAreaFraction controls the maximum fraction of the total
+// grid area that any single room's bounding box is allowed to occupy, when
+// Config.MaxRoomAreaFraction is zero.
+const defaultMaxRoomAreaFraction = 0.05
 
-// maxTotalRoomAreaFraction controls the maximum fraction of the grid area
-// that all rooms combined are allowed to occupy.
-const maxTotalRoomAreaFraction = 0.75
+// defaultMaxTotalRoomAreaFraction controls the maximum fraction of the grid
+// area that all rooms combined are allowed to occupy, when
+// Config.MaxTotalRoomAreaFraction is zero.
+const defaultMaxTotalRoomAreaFraction = 0.75
 
-// minRoomGap is the minimum number of tiles that should separate the
-// bounding boxes of any two rooms. This helps prevent rooms from being
-// squished directly against each other.
-const minRoomGap = 4
+// defaultMinRoomGap is the minimum number of tiles that should separate
+// the bounding boxes of any two rooms, when Config.MinRoomGap is zero.
+// This helps prevent rooms from being squished directly against each other.
+const defaultMinRoomGap = 4
+
+// minRoomSide is the smallest width or height a room may have.
+const minRoomSide = 3
+
+func (g *Generator) maxRoomAreaFraction() float64 {
+	if g.cfg.MaxRoomAreaFraction > 0 {
+		return g.cfg.MaxRoomAreaFraction
+	}
+	return defaultMaxRoomAreaFraction
+}
+
+func (g *Generator) maxTotalRoomAreaFraction() float64 {
+	if g.cfg.MaxTotalRoomAreaFraction > 0 {
+		return g.cfg.MaxTotalRoomAreaFraction
+	}
+	return defaultMaxTotalRoomAreaFraction
+}
+
+func (g *Generator) minRoomGap() int32 {
+	if g.cfg.MinRoomGap > 0 {
+		return g.cfg.MinRoomGap
+	}
+	return defaultMinRoomGap
+}
 
 // roomDimensions returns random width and height for the bounding box of a
-// given room shape. Dimensions are constrained so that the room stays
-// within a reasonable size relative to the overall plane and preserves the
-// basic proportions of each shape.
+// given room shape. Dimensions stay within the shape's size limits (see
+// SizeRule) and within a reasonable size relative to the overall plane, and
+// preserve the basic proportions of each shape.
 func (g *Generator) roomDimensions(shape model.RoomId) (width, height int32) {
 	plane := g.cfg.Grid
 	gridWidth := plane.MaxX - plane.MinX
 	gridHeight := plane.MaxY - plane.MinY
 	gridArea := gridWidth * gridHeight
-	maxArea := max(int32(maxRoomAreaFraction*float64(gridArea)), 9)
+	maxArea := max(int32(g.maxRoomAreaFraction()*float64(gridArea)), minRoomSide*minRoomSide)
 
-	minSize := int32(3)
+	rule := g.cfg.ShapeSizes[shape]
 
 	switch shape {
 	case model.Rectangle, model.Triangle:
 		// Allow independent width/height up to half the grid each, but
 		// constrained by the maxArea.
-		maxW := max(gridWidth/2, minSize)
-		maxH := max(gridHeight/2, minSize)
+		lim := g.narrow(rule, g.limitsFor(rule, gridWidth/2, gridHeight/2))
 
 		// Try random dimensions that satisfy the area constraint.
 		for range 10 {
-			w := g.rng.Int31n(maxW-minSize+1) + minSize
-			h := g.rng.Int31n(maxH-minSize+1) + minSize
+			w := g.sample(rule, lim.minW, lim.maxW)
+			h := g.sample(rule, lim.minH, lim.maxH)
 			if w*h <= maxArea {
 				return w, h
 			}
 		}
 
 		// Fallback: derive dimensions directly from the max area.
-		w := min(max(int32(math.Sqrt(float64(maxArea))), minSize), maxW)
-		h := min(max(maxArea/w, minSize), maxH)
+		w := min(max(int32(math.Sqrt(float64(maxArea))), lim.minW), lim.maxW)
+		h := min(max(maxArea/w, lim.minH), lim.maxH)
 		return w, h
 
 	case model.Circle, model.Square:
 		// Circles and squares use a square bounding box.
 		maxSideByPlane := min(gridHeight, gridWidth)
 		maxSideByArea := int32(math.Sqrt(float64(maxArea)))
-		maxSide := max(min(maxSideByArea, maxSideByPlane), minSize)
+		maxSide := min(maxSideByArea, maxSideByPlane)
+		lim := g.narrow(rule, g.limitsFor(rule, maxSide, maxSide))
 
-		side := g.rng.Int31n(maxSide-minSize+1) + minSize
+		lo := max(lim.minW, lim.minH)
+		hi := max(min(lim.maxW, lim.maxH), lo)
+		side := g.sample(rule, lo, hi)
 		return side, side
 
 	default:
 		// Reasonable default: small square room.
-		return minSize, minSize
+		lim := g.limitsFor(rule, minRoomSide, minRoomSide)
+		return lim.minW, lim.minH
 	}
 }
 
@@ -102,10 +130,12 @@ func (g *Generator) roomEdges(shape model.RoomId) (topLeft, bottomRight model.Ce
 	return
 }
 
-func (g *Generator) RandomRoom() model.Room {
+// RandomRoom returns a room of a random configured shape at a random
+// position. It returns ErrNoShapes when no shapes are configured.
+func (g *Generator) RandomRoom() (model.Room, error) {
 	shapes := g.cfg.RoomShapes
 	if len(shapes) == 0 {
-		panic("no room shapes configured")
+		return model.Room{}, ErrNoShapes
 	}
 
 	shape := shapes[g.rng.Intn(len(shapes))]
@@ -115,7 +145,7 @@ func (g *Generator) RandomRoom() model.Room {
 		Shape:       shape,
 		TopLeft:     topLeft,
 		BottomRight: bottomRight,
-	}
+	}, nil
 }
 
 // roomArea returns the area of the room's bounding box.
@@ -128,6 +158,11 @@ func roomArea(r model.Room) int32 {
 	return width * height
 }
 
+// roomCentre returns the middle cell of r's bounding box.
+func roomCentre(r model.Room) model.Cell {
+	return model.Cell{X: (r.TopLeft.X + r.BottomRight.X) / 2, Y: (r.TopLeft.Y + r.BottomRight.Y) / 2}
+}
+
 // roomsTooClose reports whether two rooms are closer than the specified
 // gap, based on their bounding boxes.
 func roomsTooClose(a, b model.Room, gap int32) bool {
@@ -159,37 +194,57 @@ func tooCloseToStart(r model.Room, starts []model.Cell, gap int32) bool {
 
 // Rooms generates up to maxRooms rooms, enforcing both a minimum spacing
 // between rooms and a cap on the total area that all rooms may occupy.
-func (g *Generator) Rooms(starts []model.Cell, maxRooms int) []model.Room {
+// Fewer rooms are returned when the constraints leave no more space.
+func (g *Generator) Rooms(starts []model.Cell, maxRooms int) ([]model.Room, error) {
 	plane := g.cfg.Grid
 	gridWidth := plane.MaxX - plane.MinX
 	gridHeight := plane.MaxY - plane.MinY
 	gridArea := gridWidth * gridHeight
-	fmt.Println(gridArea, gridHeight, gridWidth, plane)
-	maxTotalArea := int32(maxTotalRoomAreaFraction * float64(gridArea))
+	maxTotalArea := int32(g.maxTotalRoomAreaFraction() * float64(gridArea))
+	gap := g.minRoomGap()
 	if maxTotalArea <= 0 {
 		maxTotalArea = gridArea
 	}
 
 	rooms := make([]model.Room, 0, maxRooms)
 	var usedArea int32
+	var preview model.Dungeon
+	if g.recorder != nil {
+		preview = model.NewDungeon(plane)
+		preview.Starts = starts
+	}
 
 	for len(rooms) < maxRooms {
 		success := false
 		// Try several times to place a room that satisfies constraints.
 		for range 200 {
-			candidate := g.RandomRoom()
+			if err := g.budget.tick(); err != nil {
+				return nil, err
+			}
+			candidate, err := g.RandomRoom()
+			if err != nil {
+				return nil, err
+			}
+			// Leave room for the wall ring inside the grid edge.
+			if !plane.RoomInBoundsWithPadding(candidate.TopLeft, 2) || !plane.RoomInBoundsWithPadding(candidate.BottomRight, 2) {
+				g.emit(RoomRejected{Candidate: candidate, Reason: RejectOutOfBounds})
+				continue
+			}
 			area := roomArea(candidate)
 			if area == 0 || usedArea+area > maxTotalArea {
+				g.emit(RoomRejected{Candidate: candidate, Reason: RejectAreaCap})
+				continue
+			}
+
+			if tooCloseToStart(candidate, starts, gap) {
+				g.emit(RoomRejected{Candidate: candidate, Reason: RejectNearStart})
 				continue
 			}
 
 			tooClose := false
 			for _, existing := range rooms {
-				if tooCloseToStart(candidate, starts, minRoomGap) {
-					tooClose = true
-					break
-				}
-				if roomsTooClose(existing, candidate, minRoomGap) {
+				if roomsTooClose(existing, candidate, gap) {
+					g.emit(RoomRejected{Candidate: candidate, Reason: RejectNearRoom})
 					tooClose = true
 					break
 				}
@@ -201,6 +256,11 @@ func (g *Generator) Rooms(starts []model.Cell, maxRooms int) []model.Room {
 			rooms = append(rooms, candidate)
 			usedArea += area
 			success = true
+			g.emit(RoomPlaced{Room: len(rooms) - 1, Placed: candidate})
+			if g.recorder != nil {
+				preview.Rooms = rooms
+				g.record(StepRoomPlaced, &preview)
+			}
 			break
 		}
 
@@ -210,7 +270,7 @@ func (g *Generator) Rooms(starts []model.Cell, maxRooms int) []model.Room {
 		}
 	}
 
-	return rooms
+	return rooms, nil
 }
 
 // fillRectRoom marks all cells inside the rectangular bounds of the room.
@@ -224,22 +284,27 @@ func (g *Generator) eachRect(room model.Room, fn func(model.Cell)) {
 
 // fillCircleRoom approximates a circle inside the room's bounding box,
 // smoothing the corners compared to a plain rectangle.
+//
+// The test is done in doubled integer coordinates so the same room
+// rasterizes identically on every platform; floating point expressions may
+// be fused differently by the compiler on different architectures.
 func (g *Generator) eachCircle(room model.Room, fn func(model.Cell)) {
-	// Compute center of the bounding box.
-	cx := float64(room.TopLeft.X+room.BottomRight.X) / 2.0
-	cy := float64(room.TopLeft.Y+room.BottomRight.Y) / 2.0
+	// Twice the center of the bounding box.
+	cx2 := int64(room.TopLeft.X) + int64(room.BottomRight.X)
+	cy2 := int64(room.TopLeft.Y) + int64(room.BottomRight.Y)
 
-	width := float64(room.BottomRight.X-room.TopLeft.X) + 1.0
-	height := float64(room.BottomRight.Y-room.TopLeft.Y) + 1.0
-	// Use the smaller half-dimension as radius.
-	r := math.Min(width, height) / 2.0
-	r2 := r * r
+	width := int64(room.BottomRight.X-room.TopLeft.X) + 1
+	height := int64(room.BottomRight.Y-room.TopLeft.Y) + 1
+	// Use the smaller dimension as diameter: (2r)^2 = d^2.
+	d := min(width, height)
+	// dx^2+dy^2 <= r^2+0.25, scaled by 4.
+	limit := d*d + 1
 
 	for y := room.TopLeft.Y; y <= room.BottomRight.Y; y++ {
 		for x := room.TopLeft.X; x <= room.BottomRight.X; x++ {
-			dx := float64(x) - cx
-			dy := float64(y) - cy
-			if dx*dx+dy*dy <= r2+0.25 {
+			dx := 2*int64(x) - cx2
+			dy := 2*int64(y) - cy2
+			if dx*dx+dy*dy <= limit {
 				fn(model.Cell{X: x, Y: y})
 			}
 		}
@@ -259,40 +324,61 @@ func (g *Generator) eachTriangle(room model.Room, fn func(model.Cell)) {
 		return
 	}
 
-	height := float64(baseY - apexY)
+	height := int64(baseY - apexY)
 	if height == 0 {
 		// single row, again just a rectangle
 		g.eachRect(room, fn)
 		return
 	}
 
-	// horizontal center and maximum half-width
-	cx := float64(room.TopLeft.X+room.BottomRight.X) / 2.0
-	maxHalfWidth := float64(room.BottomRight.X-room.TopLeft.X) / 2.0
+	// The row at t = (y-apexY)/height spans cx ± t*(x2-x1)/2. Both ends are
+	// computed as exact fractions over 2*height so the row never rounds
+	// outside the bounding box and is the same on every platform.
+	x1, x2 := int64(room.TopLeft.X), int64(room.BottomRight.X)
+	den := 2 * height
 
 	for y := apexY; y <= baseY; y++ {
-		// t goes from 0 at apex to 1 at base
-		t := float64(y-apexY) / height
-		halfWidth := maxHalfWidth * t
-		minX := int32(math.Floor(cx - halfWidth))
-		maxX := int32(math.Ceil(cx + halfWidth))
+		dy := int64(y - apexY)
+		minX := int32(floorDiv((x1+x2)*height-(x2-x1)*dy, den))
+		maxX := int32(ceilDiv((x1+x2)*height+(x2-x1)*dy, den))
 		for x := minX; x <= maxX; x++ {
 			fn(model.Cell{X: x, Y: y})
 		}
 	}
 }
 
+// floorDiv returns a/b rounded towards negative infinity, for b > 0.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// ceilDiv returns a/b rounded towards positive infinity, for b > 0.
+func ceilDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a > 0 {
+		q++
+	}
+	return q
+}
+
 // AddRoomEdges marks the cells of each room in the map
 // according to its shape so that they appear in the drawn grid.
 // avoids collisions with existing tiles.
 func (g *Generator) AddRoomEdges(d *model.Dungeon, rooms []model.Room) {
-	for _, room := range rooms {
+	for i, room := range rooms {
 		g.ForEachRoomCell(room, func(c model.Cell) {
 			if d.At(c) == model.TileEmpty {
 				d.Set(c, model.TileRoomFloor)
 			}
 		})
-		DrawWallsAroundRoom(d, room, g.ForEachRoomCell)
+		drawWalls(d, room, g.ForEachRoomCell, func(c model.Cell) {
+			g.emit(WallAdded{Room: i, Cell: c})
+		})
+		g.record(StepWallsAdded, d)
 	}
 
 }
@@ -311,6 +397,12 @@ func (g *Generator) ForEachRoomCell(room model.Room, fn func(model.Cell)) {
 }
 
 func DrawWallsAroundRoom(d *model.Dungeon, room model.Room, forEachRoomCell func(model.Room, func(model.Cell))) {
+	drawWalls(d, room, forEachRoomCell, nil)
+}
+
+// drawWalls is DrawWallsAroundRoom, calling added, when not nil, with each
+// wall tile it sets.
+func drawWalls(d *model.Dungeon, room model.Room, forEachRoomCell func(model.Room, func(model.Cell)), added func(model.Cell)) {
 	dirs := []model.Cell{
 		{X: 1, Y: 0}, {X: -1, Y: 0},
 		{X: 0, Y: 1}, {X: 0, Y: -1},
@@ -328,6 +420,9 @@ func DrawWallsAroundRoom(d *model.Dungeon, room model.Room, forEachRoomCell func
 			w := model.Cell{X: c.X + di.X, Y: c.Y + di.Y}
 			if d.At(w) == model.TileEmpty && !d.Grid.OnYBoundary(w) {
 				d.Set(w, model.TileWall)
+				if added != nil {
+					added(w)
+				}
 			}
 		}
 	})
@@ -0,0 +1,183 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SizeDist selects how room dimensions are drawn between their limits.
+type SizeDist int
+
+const (
+	// SizeUniform draws every size in range with equal probability.
+	SizeUniform SizeDist = iota
+	// SizeNormal favours sizes near the middle of the range.
+	SizeNormal
+	// SizeBuckets picks a weighted SizeBucket, then a uniform size in it.
+	SizeBuckets
+)
+
+var sizeDistName = map[SizeDist]string{
+	SizeUniform: "uniform",
+	SizeNormal:  "normal",
+	SizeBuckets: "buckets",
+}
+
+func (s SizeDist) String() string {
+	if name, ok := sizeDistName[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s SizeDist) MarshalText() ([]byte, error) {
+	name, ok := sizeDistName[s]
+	if !ok {
+		return nil, fmt.Errorf("unknown size distribution %d", int(s))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *SizeDist) UnmarshalText(b []byte) error {
+	for id, name := range sizeDistName {
+		if strings.EqualFold(name, string(b)) {
+			*s = id
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown size distribution %q", string(b))
+}
+
+// SizeRule overrides room size limits for one shape. Zero limits fall back
+// to Config.RoomMinW and friends, and then to the generator defaults.
+type SizeRule struct {
+	MinW int32
+	MaxW int32
+	MinH int32
+	MaxH int32
+
+	Dist SizeDist
+	// Spread is the standard deviation of SizeNormal as a fraction of the
+	// range; 0 selects 0.25.
+	Spread float64
+	// Buckets are the choices for SizeBuckets.
+	Buckets []SizeBucket
+}
+
+// SizeBucket is one weighted size range of a SizeBuckets rule. Zero limits
+// inherit the rule's limits.
+type SizeBucket struct {
+	MinW   int32
+	MaxW   int32
+	MinH   int32
+	MaxH   int32
+	Weight int
+}
+
+// defaultSpread is the SizeNormal standard deviation, relative to the size
+// range, used when SizeRule.Spread is zero.
+const defaultSpread = 0.25
+
+// sizeLimits is an inclusive range for each room dimension.
+type sizeLimits struct {
+	minW, maxW int32
+	minH, maxH int32
+}
+
+// limitsFor resolves size limits from a shape's SizeRule, then the Config,
+// then minRoomSide and the given default maxima.
+func (g *Generator) limitsFor(rule SizeRule, defMaxW, defMaxH int32) sizeLimits {
+	pick := func(vals ...int32) int32 {
+		for _, v := range vals {
+			if v > 0 {
+				return v
+			}
+		}
+		return 0
+	}
+
+	lim := sizeLimits{
+		minW: pick(rule.MinW, g.cfg.RoomMinW, minRoomSide),
+		maxW: pick(rule.MaxW, g.cfg.RoomMaxW, defMaxW),
+		minH: pick(rule.MinH, g.cfg.RoomMinH, minRoomSide),
+		maxH: pick(rule.MaxH, g.cfg.RoomMaxH, defMaxH),
+	}
+	lim.maxW = max(lim.maxW, lim.minW)
+	lim.maxH = max(lim.maxH, lim.minH)
+	return lim
+}
+
+// narrow applies a SizeBuckets rule by choosing one bucket by weight and
+// intersecting its limits with lim. Other distributions return lim as is.
+func (g *Generator) narrow(rule SizeRule, lim sizeLimits) sizeLimits {
+	if rule.Dist != SizeBuckets || len(rule.Buckets) == 0 {
+		return lim
+	}
+
+	total := 0
+	for _, b := range rule.Buckets {
+		total += max(b.Weight, 0)
+	}
+	if total == 0 {
+		return lim
+	}
+
+	r := g.rng.Intn(total)
+	var b SizeBucket
+	for _, b = range rule.Buckets {
+		r -= max(b.Weight, 0)
+		if r < 0 {
+			break
+		}
+	}
+
+	clampTo := func(lo, hi, bLo, bHi int32) (int32, int32) {
+		if bLo > 0 {
+			lo = min(max(lo, bLo), hi)
+		}
+		if bHi > 0 {
+			hi = max(min(hi, bHi), lo)
+		}
+		return lo, hi
+	}
+	lim.minW, lim.maxW = clampTo(lim.minW, lim.maxW, b.MinW, b.MaxW)
+	lim.minH, lim.maxH = clampTo(lim.minH, lim.maxH, b.MinH, b.MaxH)
+	return lim
+}
+
+// sample draws one dimension in [lo, hi] using the rule's distribution.
+func (g *Generator) sample(rule SizeRule, lo, hi int32) int32 {
+	if rule.Dist != SizeNormal {
+		return g.rng.Int31n(hi-lo+1) + lo
+	}
+	if hi <= lo {
+		return lo
+	}
+
+	spread := rule.Spread
+	if spread <= 0 {
+		spread = defaultSpread
+	}
+	mid := float64(lo+hi) / 2
+	sd := float64(hi-lo) * spread
+	for range 10 {
+		// Irwin-Hall: the sum of 12 uniforms minus 6 approximates a
+		// standard normal using only additions, so it is reproducible on
+		// every platform unlike rand.NormFloat64, which calls math.Exp.
+		var z float64
+		for range 12 {
+			z += g.rng.Float64()
+		}
+		z -= 6
+		// The explicit conversion stops the compiler fusing the
+		// multiply-add, which would round differently per architecture.
+		v := int32(math.Round(mid + float64(sd*z)))
+		if v >= lo && v <= hi {
+			return v
+		}
+	}
+	return min(max(int32(math.Round(mid)), lo), hi)
+}
@@ -0,0 +1,315 @@
+package generator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// FieldError describes a single invalid Config field. Err, when set, is a
+// sentinel such as ErrNoShapes that callers can match with errors.Is.
+type FieldError struct {
+	Field string
+	Msg   string
+	Err   error
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+func (e FieldError) Unwrap() error { return e.Err }
+
+// ValidationError collects every FieldError found in a Config.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap exposes every FieldError to errors.Is and errors.As.
+func (e ValidationError) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Validate checks the Config for values the generator cannot work with and
+// returns a ValidationError listing every offending field, or nil.
+func (c Config) Validate() error {
+	var errs ValidationError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	g := c.Grid
+	if g.MinX > g.MaxX {
+		add("Grid.MinX", "%d is greater than Grid.MaxX (%d)", g.MinX, g.MaxX)
+	}
+	if g.MinY > g.MaxY {
+		add("Grid.MinY", "%d is greater than Grid.MaxY (%d)", g.MinY, g.MaxY)
+	}
+
+	if c.MaxRooms < 0 {
+		add("MaxRooms", "must not be negative, got %d", c.MaxRooms)
+	}
+
+	if len(c.RoomShapes) == 0 && c.Mode.usesShapes() {
+		errs = append(errs, FieldError{Field: "RoomShapes", Msg: "at least one shape is required", Err: ErrNoShapes})
+	}
+	for i, s := range c.RoomShapes {
+		if !s.Valid() {
+			add(fmt.Sprintf("RoomShapes[%d]", i), "unknown shape %d", int(s))
+		}
+	}
+
+	checkRange := func(minField, maxField string, lo, hi int32) {
+		if lo < 0 {
+			add(minField, "must not be negative, got %d", lo)
+		}
+		if hi < 0 {
+			add(maxField, "must not be negative, got %d", hi)
+		}
+		if lo > 0 && hi > 0 && lo > hi {
+			add(minField, "%d is greater than %s (%d)", lo, maxField, hi)
+		}
+	}
+	checkRange("RoomMinW", "RoomMaxW", c.RoomMinW, c.RoomMaxW)
+	checkRange("RoomMinH", "RoomMaxH", c.RoomMinH, c.RoomMaxH)
+
+	// Only check the grid against room sizes once the grid itself is sane.
+	if g.MinX <= g.MaxX && g.MinY <= g.MaxY {
+		minW := max(c.RoomMinW, minRoomSide)
+		minH := max(c.RoomMinH, minRoomSide)
+		if g.Width() < minW {
+			add("Grid", "width %d is too small for a room at least %d wide", g.Width(), minW)
+		}
+		if g.Height() < minH {
+			add("Grid", "height %d is too small for a room at least %d high", g.Height(), minH)
+		}
+	}
+
+	if c.MaxRoomAreaFraction < 0 || c.MaxRoomAreaFraction > 1 {
+		add("MaxRoomAreaFraction", "must be between 0 and 1, got %g", c.MaxRoomAreaFraction)
+	}
+	if c.MaxTotalRoomAreaFraction < 0 || c.MaxTotalRoomAreaFraction > 1 {
+		add("MaxTotalRoomAreaFraction", "must be between 0 and 1, got %g", c.MaxTotalRoomAreaFraction)
+	}
+	if c.MinRoomGap < 0 {
+		add("MinRoomGap", "must not be negative, got %d", c.MinRoomGap)
+	}
+	if c.Loops < 0 || c.Loops > 1 {
+		add("Loops", "must be between 0 and 1, got %g", c.Loops)
+	}
+
+	for _, shape := range slices.Sorted(maps.Keys(c.ShapeSizes)) {
+		rule := c.ShapeSizes[shape]
+		prefix := fmt.Sprintf("ShapeSizes[%s].", shape)
+		if !shape.Valid() {
+			add(fmt.Sprintf("ShapeSizes[%d]", int(shape)), "unknown shape")
+			continue
+		}
+		checkRange(prefix+"MinW", prefix+"MaxW", rule.MinW, rule.MaxW)
+		checkRange(prefix+"MinH", prefix+"MaxH", rule.MinH, rule.MaxH)
+		if g.MinX <= g.MaxX && rule.MinW > g.Width() {
+			add(prefix+"MinW", "%d is wider than the grid (%d)", rule.MinW, g.Width())
+		}
+		if g.MinY <= g.MaxY && rule.MinH > g.Height() {
+			add(prefix+"MinH", "%d is higher than the grid (%d)", rule.MinH, g.Height())
+		}
+
+		switch rule.Dist {
+		case SizeUniform:
+		case SizeNormal:
+			if rule.Spread < 0 {
+				add(prefix+"Spread", "must not be negative, got %g", rule.Spread)
+			}
+		case SizeBuckets:
+			if len(rule.Buckets) == 0 {
+				add(prefix+"Buckets", "at least one bucket is required for the buckets distribution")
+			}
+			for i, b := range rule.Buckets {
+				bp := fmt.Sprintf("%sBuckets[%d].", prefix, i)
+				checkRange(bp+"MinW", bp+"MaxW", b.MinW, b.MaxW)
+				checkRange(bp+"MinH", bp+"MaxH", b.MinH, b.MaxH)
+				if b.Weight <= 0 {
+					add(bp+"Weight", "must be positive, got %d", b.Weight)
+				}
+			}
+		default:
+			add(prefix+"Dist", "unknown distribution %d", int(rule.Dist))
+		}
+	}
+
+	if len(c.Doors.Counts) > 0 && (c.Mode == ModeGraph || c.Mode == ModeMST) {
+		add("Doors.Counts", "%s mode gives a room one door per corridor", c.Mode)
+	}
+	for _, shape := range slices.Sorted(maps.Keys(c.Doors.Counts)) {
+		if !shape.Valid() {
+			add(fmt.Sprintf("Doors.Counts[%d]", int(shape)), "unknown shape")
+			continue
+		}
+		r := c.Doors.Counts[shape]
+		prefix := fmt.Sprintf("Doors.Counts[%s].", shape)
+		checkRange(prefix+"Min", prefix+"Max", r.Min, r.Max)
+	}
+	if c.Doors.Spacing < 0 {
+		add("Doors.Spacing", "must not be negative, got %d", c.Doors.Spacing)
+	}
+
+	if c.MaxIterations < 0 {
+		add("MaxIterations", "must not be negative, got %d", c.MaxIterations)
+	}
+	if c.Timeout < 0 {
+		add("Timeout", "must not be negative, got %v", c.Timeout)
+	}
+
+	for i, s := range c.RoomShapes {
+		if s == model.Cave {
+			add(fmt.Sprintf("RoomShapes[%d]", i), "caves are only made by the cave mode")
+		}
+	}
+
+	if cv := c.Cave; cv.Fill < 0 || cv.Fill >= 1 {
+		add("Cave.Fill", "must be at least 0 and below 1, got %g", cv.Fill)
+	}
+	if cv := c.Cave; cv.Birth < 0 || cv.Birth > 8 {
+		add("Cave.Birth", "must be between 0 and 8, got %d", cv.Birth)
+	}
+	if cv := c.Cave; cv.Survival < 0 || cv.Survival > 8 {
+		add("Cave.Survival", "must be between 0 and 8, got %d", cv.Survival)
+	}
+	if c.Cave.Steps < 0 {
+		add("Cave.Steps", "must not be negative, got %d", c.Cave.Steps)
+	}
+	if c.Cave.MinRegion < 0 {
+		add("Cave.MinRegion", "must not be negative, got %d", c.Cave.MinRegion)
+	}
+	if cv := c.Cave; cv.Ratio < 0 || cv.Ratio > 1 {
+		add("Cave.Ratio", "must be between 0 and 1, got %g", cv.Ratio)
+	}
+
+	if c.Walk.Walkers < 0 {
+		add("Walk.Walkers", "must not be negative, got %d", c.Walk.Walkers)
+	}
+	if c.Walk.Steps < 0 {
+		add("Walk.Steps", "must not be negative, got %d", c.Walk.Steps)
+	}
+	if w := c.Walk; w.Turn < 0 || w.Turn > 1 {
+		add("Walk.Turn", "must be between 0 and 1, got %g", w.Turn)
+	}
+	if w := c.Walk; w.Coverage < 0 || w.Coverage > 1 {
+		add("Walk.Coverage", "must be between 0 and 1, got %g", w.Coverage)
+	}
+	if c.Walk.EdgeMargin < 0 {
+		add("Walk.EdgeMargin", "must not be negative, got %d", c.Walk.EdgeMargin)
+	}
+
+	if c.Mode == ModeGraph && len(c.Graph.Nodes) == 0 {
+		errs = append(errs, FieldError{Field: "Graph.Nodes", Msg: "at least one node is required by the graph mode", Err: ErrNoGraph})
+	}
+	names := make(map[string]bool)
+	for i, n := range c.Graph.Nodes {
+		field := fmt.Sprintf("Graph.Nodes[%d]", i)
+		switch {
+		case n.Name == "":
+			add(field+".Name", "is required")
+		case names[n.Name]:
+			add(field+".Name", "%q is used by another node", n.Name)
+		}
+		names[n.Name] = true
+		if !n.Shape.Valid() {
+			add(field+".Shape", "unknown room shape %d", int(n.Shape))
+		} else if n.Shape == model.Cave {
+			add(field+".Shape", "caves are only made by the cave mode")
+		}
+		if n.W != 0 && n.W < minRoomSide {
+			add(field+".W", "must be 0 or at least %d, got %d", minRoomSide, n.W)
+		}
+		if n.H != 0 && n.H < minRoomSide {
+			add(field+".H", "must be 0 or at least %d, got %d", minRoomSide, n.H)
+		}
+	}
+	for i, e := range c.Graph.Edges {
+		field := fmt.Sprintf("Graph.Edges[%d]", i)
+		if !names[e.From] {
+			add(field+".From", "unknown node %q", e.From)
+		}
+		if !names[e.To] {
+			add(field+".To", "unknown node %q", e.To)
+		}
+		if e.From == e.To {
+			add(field, "joins %q to itself", e.From)
+		}
+		if _, ok := doorKindName[e.Door]; !ok {
+			add(field+".Door", "unknown door kind %d", int(e.Door))
+		}
+	}
+	if order, ok := c.Graph.order(); !ok {
+		add("Graph.Edges", "%d of %d nodes cannot be reached from %q", len(c.Graph.Nodes)-len(order), len(c.Graph.Nodes), c.Graph.Nodes[0].Name)
+	}
+
+	if c.Mode == ModeWFC && len(c.WFC.Sample) == 0 {
+		errs = append(errs, FieldError{Field: "WFC.Sample", Msg: "a sample is required by the wfc mode", Err: ErrNoSample})
+	}
+	if len(c.WFC.Sample) > 0 && len(c.WFC.Sample[0]) == 0 {
+		add("WFC.Sample[0]", "must not be empty")
+	}
+	for i, row := range c.WFC.Sample {
+		if len(row) != len(c.WFC.Sample[0]) {
+			add(fmt.Sprintf("WFC.Sample[%d]", i), "has %d tiles, want %d like the first row", len(row), len(c.WFC.Sample[0]))
+		}
+		for j, t := range row {
+			if t > model.TileWall {
+				add(fmt.Sprintf("WFC.Sample[%d][%d]", i, j), "unknown tile %d", int(t))
+			}
+		}
+	}
+	if w := c.WFC; w.N < 0 {
+		add("WFC.N", "must not be negative, got %d", w.N)
+	} else if n := w.n(); len(w.Sample) > 0 && len(w.Sample[0]) > 0 && !w.PeriodicInput && (n > len(w.Sample) || n > len(w.Sample[0])) {
+		add("WFC.N", "%d is larger than the %dx%d sample", n, len(w.Sample[0]), len(w.Sample))
+	} else if c.Mode == ModeWFC && !w.Periodic && (int64(n) > int64(g.Width()) || int64(n) > int64(g.Height())) {
+		add("WFC.N", "%d is larger than the %dx%d grid", n, g.Width(), g.Height())
+	}
+	if w := c.WFC; w.Symmetry < 0 || w.Symmetry > 8 {
+		add("WFC.Symmetry", "must be between 0 and 8, got %d", w.Symmetry)
+	}
+	if c.WFC.Backtracks < 0 {
+		add("WFC.Backtracks", "must not be negative, got %d", c.WFC.Backtracks)
+	}
+
+	if _, ok := modeName[c.Mode]; !ok {
+		add("Mode", "unknown mode %d", int(c.Mode))
+	}
+
+	for i, r := range c.Repair {
+		if _, ok := repairName[r]; !ok {
+			add(fmt.Sprintf("Repair[%d]", i), "unknown repair strategy %d", int(r))
+		}
+		if r == RepairNone && len(c.Repair) > 1 {
+			add(fmt.Sprintf("Repair[%d]", i), "none cannot be combined with other strategies")
+		}
+	}
+
+	if c.CorridorW < 0 {
+		add("CorridorW", "must not be negative, got %d", c.CorridorW)
+	}
+	if c.CorridorBuff < 0 {
+		add("CorridorBuff", "must not be negative, got %d", c.CorridorBuff)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
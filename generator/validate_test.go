@@ -0,0 +1,78 @@
+package generator_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+func TestValidate(t *testing.T) {
+	for _, name := range generator.ModeNames() {
+		var mode generator.Mode
+		if err := mode.UnmarshalText([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		if err := testConfig(t, mode).Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  generator.Mode
+		edit  func(c *generator.Config)
+		field string
+		err   error // a sentinel the FieldError wraps, if any
+	}{
+		{"corridor width", generator.ModeRooms, func(c *generator.Config) { c.CorridorW = -1 }, "CorridorW", nil},
+		{"corridor buffer", generator.ModeRooms, func(c *generator.Config) { c.CorridorBuff = -2 }, "CorridorBuff", nil},
+		{"grid", generator.ModeRooms, func(c *generator.Config) { c.Grid.MinX = c.Grid.MaxX + 1 }, "Grid.MinX", nil},
+		{"rooms without shapes", generator.ModeRooms, func(c *generator.Config) { c.RoomShapes = nil }, "RoomShapes", generator.ErrNoShapes},
+		{"bsp without shapes", generator.ModeBSP, func(c *generator.Config) { c.RoomShapes = nil }, "RoomShapes", generator.ErrNoShapes},
+		{"hybrid without shapes", generator.ModeHybrid, func(c *generator.Config) { c.RoomShapes = nil }, "RoomShapes", generator.ErrNoShapes},
+		{"mst without shapes", generator.ModeMST, func(c *generator.Config) { c.RoomShapes = nil }, "RoomShapes", generator.ErrNoShapes},
+		{"cave shape", generator.ModeRooms, func(c *generator.Config) { c.RoomShapes = []model.RoomId{model.Cave} }, "RoomShapes[0]", nil},
+		{"door range", generator.ModeRooms, func(c *generator.Config) {
+			c.Doors.Counts = map[model.RoomId]generator.DoorRange{model.Circle: {Min: 3, Max: 2}}
+		}, "Doors.Counts[Circle].Min", nil},
+		{"door counts in mst", generator.ModeMST, func(c *generator.Config) {
+			c.Doors.Counts = map[model.RoomId]generator.DoorRange{model.Circle: {Min: 2}}
+		}, "Doors.Counts", nil},
+		{"graph without nodes", generator.ModeGraph, func(c *generator.Config) { c.Graph = generator.RoomGraph{} }, "Graph.Nodes", generator.ErrNoGraph},
+		{"unordered graph", generator.ModeGraph, func(c *generator.Config) {
+			// Without the entrance to hub edge nothing past the entrance
+			// can be reached from it.
+			c.Graph.Edges = c.Graph.Edges[1:]
+		}, "Graph.Edges", nil},
+		{"graph edge to nowhere", generator.ModeGraph, func(c *generator.Config) {
+			c.Graph.Edges = append(c.Graph.Edges, generator.GraphEdge{From: "hub", To: "vault"})
+		}, "Graph.Edges[5].To", nil},
+		{"wfc without sample", generator.ModeWFC, func(c *generator.Config) { c.WFC.Sample = nil }, "WFC.Sample", generator.ErrNoSample},
+		{"ragged sample", generator.ModeWFC, func(c *generator.Config) { c.WFC.Sample[2] = c.WFC.Sample[2][1:] }, "WFC.Sample[2]", nil},
+		{"repair none", generator.ModeRooms, func(c *generator.Config) {
+			c.Repair = []generator.RepairStrategy{generator.RepairNone, generator.RepairDropRoom}
+		}, "Repair[0]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.mode)
+			tt.edit(&cfg)
+			err := cfg.Validate()
+			var ve generator.ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("Validate = %v, want a ValidationError", err)
+			}
+			if !slices.ContainsFunc(ve, func(fe generator.FieldError) bool { return fe.Field == tt.field }) {
+				t.Fatalf("Validate = %v, want an error on %s", err, tt.field)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Validate = %v, want it to match %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Defaults for the zero fields of WalkConfig.
+const (
+	defaultWalkers    = 1
+	defaultWalkTurn   = 0.2
+	defaultCoverage   = 0.3
+	defaultEdgeMargin = 2
+)
+
+// walkFrameCells is how many cells a walker carves between recorded
+// frames.
+const walkFrameCells = 50
+
+// WalkConfig controls the random walkers of ModeWalk. Zero fields select
+// the defaults.
+type WalkConfig struct {
+	// Walkers is the number of walkers, which set out one after another;
+	// 0 selects 1.
+	Walkers int
+	// Steps is how many steps each walker takes at most; 0 selects the
+	// grid area.
+	Steps int
+	// Turn is the chance that a walker picks a new random direction
+	// before a step; 0 selects 0.2.
+	Turn float64
+	// Coverage is the fraction of the grid carved after which all walkers
+	// stop; 0 selects 0.3.
+	Coverage float64
+	// EdgeMargin is how many tiles walkers keep from the grid edge; a
+	// walker that would step closer turns instead. 0 selects 2. At least 1
+	// is always kept, and 2 with Floor so the walls fit inside the grid.
+	EdgeMargin int32
+	// Floor carves room floor inside one model.Cave room, walled in with
+	// DrawWallsAroundRoom, instead of bare corridor.
+	Floor bool
+}
+
+func (c WalkConfig) walkers() int {
+	if c.Walkers > 0 {
+		return c.Walkers
+	}
+	return defaultWalkers
+}
+
+func (c WalkConfig) steps(grid model.Grid) int {
+	if c.Steps > 0 {
+		return c.Steps
+	}
+	return int(grid.Width() * grid.Height())
+}
+
+func (c WalkConfig) turn() float64 {
+	if c.Turn > 0 {
+		return c.Turn
+	}
+	return defaultWalkTurn
+}
+
+func (c WalkConfig) coverage() float64 {
+	if c.Coverage > 0 {
+		return c.Coverage
+	}
+	return defaultCoverage
+}
+
+func (c WalkConfig) edgeMargin() int32 {
+	if c.EdgeMargin > 0 {
+		return c.EdgeMargin
+	}
+	return defaultEdgeMargin
+}
+
+// WalkPipeline carves tunnels with WalkPlacer, connects them to the grid
+// edge with WalkConnector and walls them in with WalkWalls.
+func WalkPipeline() Pipeline {
+	return NewPipeline(WalkPlacer{}, WalkConnector{}, WalkWalls{})
+}
+
+// WalkPlacer sends Config.Walk.Walkers random walkers through the grid,
+// one after another. The first starts in the middle of the grid and each
+// later one at a random cell already carved, so the tunnels are always
+// joined. A walker keeps its direction except when it turns, with chance
+// Config.Walk.Turn before each step or when the next step would come within
+// Config.Walk.EdgeMargin of the grid edge, and stops after
+// Config.Walk.Steps steps or once Config.Walk.Coverage of the grid is
+// carved.
+//
+// Walkers carve corridor, or with Config.Walk.Floor room floor; then the
+// carved area becomes a model.Cave room over its bounding box.
+type WalkPlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (WalkPlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	cells, err := s.g.walk(d)
+	if err != nil {
+		return err
+	}
+	s.tunnels = cells
+	return nil
+}
+
+// walk runs the walkers over d and returns the cells they carved in the
+// order they were carved.
+func (g *Generator) walk(d *model.Dungeon) ([]model.Cell, error) {
+	cfg := g.cfg.Walk
+	grid := g.cfg.Grid
+	margin := max(cfg.edgeMargin(), 1)
+	if cfg.Floor {
+		margin = max(margin, 2)
+	}
+	if grid.Width() <= 2*margin || grid.Height() <= 2*margin {
+		return nil, fmt.Errorf("grid leaves no room inside an edge margin of %d: %w", margin, ErrNoRoomsPlaced)
+	}
+
+	tile := model.TileCorridor
+	if cfg.Floor {
+		tile = model.TileRoomFloor
+	}
+	target := max(int(cfg.coverage()*float64(grid.Width()*grid.Height())), 1)
+
+	var carved []model.Cell
+	carve := func(c model.Cell) {
+		if d.At(c) == model.TileEmpty {
+			d.Set(c, tile)
+			carved = append(carved, c)
+			if len(carved)%walkFrameCells == 0 {
+				g.record(StepTunnelCarved, d)
+			}
+		}
+	}
+
+	for w := range cfg.walkers() {
+		if len(carved) >= target {
+			break
+		}
+		pos := model.Cell{X: grid.MinX + (grid.Width()-1)/2, Y: grid.MinY + (grid.Height()-1)/2}
+		if w > 0 {
+			pos = carved[g.rng.Intn(len(carved))]
+		}
+		carve(pos)
+		dir := dirs4[g.rng.Intn(len(dirs4))]
+		for range cfg.steps(grid) {
+			if len(carved) >= target {
+				break
+			}
+			if err := g.budget.tick(); err != nil {
+				return nil, err
+			}
+			if g.rng.Float64() < cfg.turn() {
+				dir = dirs4[g.rng.Intn(len(dirs4))]
+			}
+			next := model.Cell{X: pos.X + dir.X, Y: pos.Y + dir.Y}
+			if !grid.RoomInBoundsWithPadding(next, margin) {
+				dir = dirs4[g.rng.Intn(len(dirs4))]
+				continue
+			}
+			pos = next
+			carve(pos)
+		}
+		g.record(StepTunnelCarved, d)
+	}
+
+	if cfg.Floor {
+		room := model.Room{Shape: model.Cave, TopLeft: carved[0], BottomRight: carved[0]}
+		for _, c := range carved {
+			room.TopLeft.X, room.TopLeft.Y = min(room.TopLeft.X, c.X), min(room.TopLeft.Y, c.Y)
+			room.BottomRight.X, room.BottomRight.Y = max(room.BottomRight.X, c.X), max(room.BottomRight.Y, c.Y)
+		}
+		d.Rooms = []model.Room{room}
+		g.emit(RoomPlaced{Room: 0, Placed: room})
+		g.record(StepRoomPlaced, d)
+	}
+	return carved, nil
+}
+
+// WalkConnector runs a corridor from the nearest grid edge to the tunnels
+// made by WalkPlacer, whose edge cell becomes the only start. Its events
+// name room 0 when the tunnels are room floor and -1 otherwise. Without
+// tunnels, for instance after another placer, it does nothing.
+type WalkConnector struct{}
+
+// Connect implements Connector.
+func (WalkConnector) Connect(s *State, d *model.Dungeon) error {
+	if len(s.tunnels) == 0 {
+		return nil
+	}
+	room := -1
+	if len(d.Rooms) > 0 {
+		room = 0
+	}
+	ok, err := s.g.carveEntrance(d, room, s.tunnels, nil)
+	if err != nil {
+		return err
+	}
+	if !ok && room >= 0 {
+		return unreachableErr(d.Rooms, room)
+	}
+	if !ok {
+		return fmt.Errorf("tunnels: %w", ErrUnreachableRoom)
+	}
+	return nil
+}
+
+// WalkWalls walls in every room's floor with DrawWallsAroundRoom, without
+// filling in the rest of its bounding box as RoomWalls would. Corridor
+// tunnels are left open, as corridors always are.
+type WalkWalls struct{}
+
+// BuildWalls implements WallBuilder.
+func (WalkWalls) BuildWalls(s *State, d *model.Dungeon) error {
+	for i, r := range d.Rooms {
+		drawWalls(d, r, s.g.ForEachRoomCell, func(c model.Cell) {
+			s.Emit(WallAdded{Room: i, Cell: c})
+		})
+		s.Record(StepWallsAdded, d)
+	}
+	return nil
+}
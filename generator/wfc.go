@@ -0,0 +1,556 @@
+package generator
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Defaults for the zero fields of WFCConfig.
+const (
+	defaultWFCN          = 3
+	defaultWFCSymmetry   = 8
+	defaultWFCBacktracks = 1000
+)
+
+// WFCConfig controls the overlapping Wave Function Collapse of ModeWFC.
+// Zero fields other than Sample select the defaults.
+type WFCConfig struct {
+	// Sample is the example map, one row of tiles per line from the top,
+	// such as render.ParseASCII returns. All rows must be the same length.
+	Sample [][]model.Tile
+	// N is the side of the square patterns read from Sample; 0 selects 3.
+	N int
+	// Symmetry is how many of the 8 rotations and reflections of each
+	// pattern are added to the patterns, in the order: as read, mirrored,
+	// rotated a quarter turn, and so on. 0 selects 8; 1 keeps the sample's
+	// orientation.
+	Symmetry int
+	// PeriodicInput reads patterns across the edges of Sample as if it
+	// wrapped around.
+	PeriodicInput bool
+	// Periodic lets patterns cross the edges of the grid, so the dungeon
+	// tiles seamlessly.
+	Periodic bool
+	// Backtracks is how many choices may be undone after contradictions
+	// before giving up with ErrContradiction; 0 selects 1000.
+	Backtracks int
+}
+
+func (c WFCConfig) n() int {
+	if c.N > 0 {
+		return c.N
+	}
+	return defaultWFCN
+}
+
+func (c WFCConfig) symmetry() int {
+	if c.Symmetry > 0 {
+		return c.Symmetry
+	}
+	return defaultWFCSymmetry
+}
+
+func (c WFCConfig) backtracks() int {
+	if c.Backtracks > 0 {
+		return c.Backtracks
+	}
+	return defaultWFCBacktracks
+}
+
+// WFCPipeline fills the grid with WFCPlacer and nothing else; the sample's
+// walls and doors carry over with its patterns.
+func WFCPipeline() Pipeline {
+	return NewPipeline(WFCPlacer{}, nil, nil)
+}
+
+// WFCPlacer fills the grid by overlapping Wave Function Collapse on
+// Config.WFC.Sample: every N×N window of the collapse is a pattern of the
+// sample, and patterns turn up about as often as in the sample. Cells are
+// collapsed lowest entropy first, picking patterns at random by weight;
+// after a contradiction the latest choice is undone and ruled out.
+//
+// Doors left with no room floor next to them become corridor or wall, and
+// each 4-connected area of room floor then becomes a model.Cave room over
+// its bounding box, grown to hold the doors next to it. Nothing joins the
+// areas or sets starts, so the dungeon is only as connected as the sample
+// makes likely.
+type WFCPlacer struct{}
+
+// PlaceRooms implements RoomPlacer.
+func (WFCPlacer) PlaceRooms(s *State, d *model.Dungeon) error {
+	g := s.g
+	if len(g.cfg.WFC.Sample) == 0 {
+		return ErrNoSample
+	}
+	m := newWFCModel(g.cfg.WFC)
+	grid := g.cfg.Grid
+	w := newWave(m, int(grid.Width()), int(grid.Height()), g.cfg.WFC.Periodic)
+	rows, err := w.run(g)
+	if err != nil {
+		return err
+	}
+	for y, row := range rows {
+		for x, t := range row {
+			d.Set(model.Cell{X: grid.MinX + int32(x), Y: grid.MaxY - int32(y)}, t)
+		}
+	}
+	g.dropStrayDoors(d)
+	d.Rooms = g.floorRooms(d)
+	for i, r := range d.Rooms {
+		g.emit(RoomPlaced{Room: i, Placed: r})
+	}
+	g.record(StepRoomPlaced, d)
+	return nil
+}
+
+// dropStrayDoors turns every door with no room floor next to it, which
+// patterns cut off by the grid edge or another pattern can leave, into
+// corridor when it touches a corridor and into wall otherwise.
+func (g *Generator) dropStrayDoors(d *model.Dungeon) {
+	grid := g.cfg.Grid
+	g.eachCell(func(c model.Cell) {
+		if d.At(c) != model.TileDoor {
+			return
+		}
+		floor, corridor := false, false
+		for _, di := range dirs4 {
+			n := model.Cell{X: c.X + di.X, Y: c.Y + di.Y}
+			if !grid.InBounds(n) {
+				continue
+			}
+			switch d.At(n) {
+			case model.TileRoomFloor:
+				floor = true
+			case model.TileCorridor:
+				corridor = true
+			}
+		}
+		switch {
+		case floor:
+		case corridor:
+			d.Set(c, model.TileCorridor)
+		default:
+			d.Set(c, model.TileWall)
+		}
+	})
+}
+
+// floorRooms returns a Cave room for each 4-connected area of floor in d,
+// in scan order, grown to cover the doors next to it.
+func (g *Generator) floorRooms(d *model.Dungeon) []model.Room {
+	grid := g.cfg.Grid
+	seen := make(map[model.Cell]bool)
+	var rooms []model.Room
+	g.eachCell(func(c model.Cell) {
+		if seen[c] || d.At(c) != model.TileRoomFloor {
+			return
+		}
+		seen[c] = true
+		room := model.Room{Shape: model.Cave, TopLeft: c, BottomRight: c}
+		grow := func(c model.Cell) {
+			room.TopLeft.X, room.TopLeft.Y = min(room.TopLeft.X, c.X), min(room.TopLeft.Y, c.Y)
+			room.BottomRight.X, room.BottomRight.Y = max(room.BottomRight.X, c.X), max(room.BottomRight.Y, c.Y)
+		}
+		for stack := []model.Cell{c}; len(stack) > 0; {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, di := range dirs4 {
+				n := model.Cell{X: c.X + di.X, Y: c.Y + di.Y}
+				if !grid.InBounds(n) || seen[n] {
+					continue
+				}
+				switch d.At(n) {
+				case model.TileRoomFloor:
+					seen[n] = true
+					grow(n)
+					stack = append(stack, n)
+				case model.TileDoor:
+					grow(n)
+				}
+			}
+		}
+		rooms = append(rooms, room)
+	})
+	return rooms
+}
+
+// wfcModel is the set of patterns read from a sample and which of them may
+// overlap.
+type wfcModel struct {
+	n        int
+	patterns [][]model.Tile
+	weights  []int64
+	// prop[dir][t] lists the patterns that may sit one step in direction
+	// dir from pattern t.
+	prop [4][][]int
+}
+
+// Directions in sample space, where y grows downwards.
+var (
+	wfcDX       = [4]int{-1, 0, 1, 0}
+	wfcDY       = [4]int{0, 1, 0, -1}
+	wfcOpposite = [4]int{2, 3, 0, 1}
+)
+
+// newWFCModel reads the patterns of cfg.Sample, which Config.Validate has
+// checked, in order of first appearance.
+func newWFCModel(cfg WFCConfig) *wfcModel {
+	n := cfg.n()
+	sample := cfg.Sample
+	sh, sw := len(sample), len(sample[0])
+	m := &wfcModel{n: n}
+
+	index := make(map[string]int)
+	add := func(p []model.Tile) {
+		key := string(tileBytes(p))
+		if i, ok := index[key]; ok {
+			m.weights[i]++
+			return
+		}
+		index[key] = len(m.patterns)
+		m.patterns = append(m.patterns, p)
+		m.weights = append(m.weights, 1)
+	}
+
+	ymax, xmax := sh-n+1, sw-n+1
+	if cfg.PeriodicInput {
+		ymax, xmax = sh, sw
+	}
+	for y := range ymax {
+		for x := range xmax {
+			var ps [8][]model.Tile
+			ps[0] = make([]model.Tile, n*n)
+			for dy := range n {
+				for dx := range n {
+					ps[0][dx+dy*n] = sample[(y+dy)%sh][(x+dx)%sw]
+				}
+			}
+			ps[1] = reflectPattern(ps[0], n)
+			for k := 2; k < 8; k += 2 {
+				ps[k] = rotatePattern(ps[k-2], n)
+				ps[k+1] = reflectPattern(ps[k], n)
+			}
+			for _, p := range ps[:min(cfg.symmetry(), 8)] {
+				add(p)
+			}
+		}
+	}
+
+	for dir := range 4 {
+		m.prop[dir] = make([][]int, len(m.patterns))
+		for t, p := range m.patterns {
+			for t2, q := range m.patterns {
+				if agrees(p, q, wfcDX[dir], wfcDY[dir], n) {
+					m.prop[dir][t] = append(m.prop[dir][t], t2)
+				}
+			}
+		}
+	}
+	return m
+}
+
+func tileBytes(p []model.Tile) []byte {
+	b := make([]byte, len(p))
+	for i, t := range p {
+		b[i] = byte(t)
+	}
+	return b
+}
+
+// log2Fixed returns log2(x) for x >= 1 in fixed point with 16 fractional
+// bits, by repeated squaring in integer arithmetic so it is the same on
+// every platform.
+func log2Fixed(x int64) int64 {
+	n := bits.Len64(uint64(x)) - 1
+	// y is x / 2^n in [1, 2), with 62 fractional bits.
+	y := uint64(x) << (62 - n)
+	out := int64(n) << 16
+	for bit := int64(1) << 15; bit > 0; bit >>= 1 {
+		hi, lo := bits.Mul64(y, y)
+		y = hi<<2 | lo>>62
+		if y >= 1<<63 {
+			y >>= 1
+			out |= bit
+		}
+	}
+	return out
+}
+
+// reflectPattern mirrors p left to right.
+func reflectPattern(p []model.Tile, n int) []model.Tile {
+	out := make([]model.Tile, n*n)
+	for y := range n {
+		for x := range n {
+			out[x+y*n] = p[n-1-x+y*n]
+		}
+	}
+	return out
+}
+
+// rotatePattern turns p a quarter turn.
+func rotatePattern(p []model.Tile, n int) []model.Tile {
+	out := make([]model.Tile, n*n)
+	for y := range n {
+		for x := range n {
+			out[x+y*n] = p[n-1-y+x*n]
+		}
+	}
+	return out
+}
+
+// agrees reports whether q may sit at offset (dx, dy) from p, that is
+// whether they match where they overlap.
+func agrees(p, q []model.Tile, dx, dy, n int) bool {
+	xmin, xmax := max(dx, 0), min(dx+n, n)
+	ymin, ymax := max(dy, 0), min(dy+n, n)
+	for y := ymin; y < ymax; y++ {
+		for x := xmin; x < xmax; x++ {
+			if p[x+n*y] != q[x-dx+n*(y-dy)] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// wave is the state of one collapse: which patterns each cell may still
+// take, and the undo trail for backtracking.
+type wave struct {
+	m             *wfcModel
+	width, height int // output size in tiles
+	fmx, fmy      int // wave size in cells
+	periodic      bool
+
+	allowed []bool  // cell*T + t
+	compat  []int32 // (cell*T + t)*4 + dir
+	count   []int
+	// sumW and sumWLog are each cell's total weight and total of
+	// weight*log2(weight) over its allowed patterns, for the entropy.
+	sumW    []int64
+	sumWLog []int64
+	wLog    []int64
+
+	// trail lists every ban in order; applied marks those whose effect on
+	// the neighbours has been propagated. stack holds trail indexes still
+	// to propagate.
+	trail         []wfcBan
+	applied       []bool
+	stack         []int
+	contradiction bool
+}
+
+type wfcBan struct{ cell, t int }
+
+// wfcChoice is a collapse that may be undone: the cell, the pattern picked
+// and the trail length before it.
+type wfcChoice struct{ cell, t, trail int }
+
+func newWave(m *wfcModel, width, height int, periodic bool) *wave {
+	w := &wave{m: m, width: width, height: height, fmx: width, fmy: height, periodic: periodic}
+	if !periodic {
+		w.fmx, w.fmy = width-m.n+1, height-m.n+1
+	}
+	nt := len(m.patterns)
+	cells := w.fmx * w.fmy
+	w.allowed = make([]bool, cells*nt)
+	w.compat = make([]int32, cells*nt*4)
+	w.count = make([]int, cells)
+	w.sumW = make([]int64, cells)
+	w.sumWLog = make([]int64, cells)
+	w.wLog = make([]int64, nt)
+
+	var sumW, sumWLog int64
+	for t, wt := range m.weights {
+		w.wLog[t] = wt * log2Fixed(wt)
+		sumW += wt
+		sumWLog += w.wLog[t]
+	}
+	for i := range cells {
+		w.count[i] = nt
+		w.sumW[i] = sumW
+		w.sumWLog[i] = sumWLog
+		for t := range nt {
+			w.allowed[i*nt+t] = true
+			for dir := range 4 {
+				w.compat[(i*nt+t)*4+dir] = int32(len(m.prop[wfcOpposite[dir]][t]))
+			}
+		}
+	}
+	// A pattern that nothing may sit next to on some side, such as one
+	// from the sample's edge, is ruled out wherever that side has a cell.
+	// propagate only bans patterns whose count drops to zero, so these
+	// would otherwise never be banned.
+	for i := range cells {
+		for t := range nt {
+			for dir := range 4 {
+				if _, ok := w.neighbour(i, wfcOpposite[dir]); ok && w.allowed[i*nt+t] && w.compat[(i*nt+t)*4+dir] == 0 {
+					w.ban(i, t)
+				}
+			}
+		}
+	}
+	return w
+}
+
+// ban rules out pattern t at cell i.
+func (w *wave) ban(i, t int) {
+	nt := len(w.m.patterns)
+	w.allowed[i*nt+t] = false
+	w.count[i]--
+	w.sumW[i] -= w.m.weights[t]
+	w.sumWLog[i] -= w.wLog[t]
+	if w.count[i] == 0 {
+		w.contradiction = true
+	}
+	w.stack = append(w.stack, len(w.trail))
+	w.trail = append(w.trail, wfcBan{i, t})
+	w.applied = append(w.applied, false)
+}
+
+// neighbour returns the cell one step in direction dir from i, or false
+// past the edge of a non-periodic wave.
+func (w *wave) neighbour(i, dir int) (int, bool) {
+	x, y := i%w.fmx+wfcDX[dir], i/w.fmx+wfcDY[dir]
+	if w.periodic {
+		x, y = (x+w.fmx)%w.fmx, (y+w.fmy)%w.fmy
+	} else if x < 0 || y < 0 || x >= w.fmx || y >= w.fmy {
+		return 0, false
+	}
+	return x + y*w.fmx, true
+}
+
+// propagate bans every pattern left without a compatible neighbour and
+// reports false on a contradiction, stopping at once.
+func (w *wave) propagate() bool {
+	nt := len(w.m.patterns)
+	for len(w.stack) > 0 && !w.contradiction {
+		k := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+		w.applied[k] = true
+		b := w.trail[k]
+		for dir := range 4 {
+			i2, ok := w.neighbour(b.cell, dir)
+			if !ok {
+				continue
+			}
+			for _, t2 := range w.m.prop[dir][b.t] {
+				c := &w.compat[(i2*nt+t2)*4+dir]
+				*c--
+				if *c == 0 && w.allowed[i2*nt+t2] {
+					w.ban(i2, t2)
+				}
+			}
+		}
+	}
+	return !w.contradiction
+}
+
+// undo reverts every ban after the first n of the trail.
+func (w *wave) undo(n int) {
+	nt := len(w.m.patterns)
+	for k := len(w.trail) - 1; k >= n; k-- {
+		b := w.trail[k]
+		if w.applied[k] {
+			for dir := range 4 {
+				i2, ok := w.neighbour(b.cell, dir)
+				if !ok {
+					continue
+				}
+				for _, t2 := range w.m.prop[dir][b.t] {
+					w.compat[(i2*nt+t2)*4+dir]++
+				}
+			}
+		}
+		w.allowed[b.cell*nt+b.t] = true
+		w.count[b.cell]++
+		w.sumW[b.cell] += w.m.weights[b.t]
+		w.sumWLog[b.cell] += w.wLog[b.t]
+	}
+	w.trail, w.applied = w.trail[:n], w.applied[:n]
+	w.stack = w.stack[:0]
+	w.contradiction = false
+}
+
+// run collapses the wave with g's RNG and returns the output tiles, one
+// row per line from the top.
+func (w *wave) run(g *Generator) ([][]model.Tile, error) {
+	nt := len(w.m.patterns)
+	var choices []wfcChoice
+	backtracks := 0
+	for {
+		if err := g.budget.tick(); err != nil {
+			return nil, err
+		}
+		for !w.propagate() {
+			if len(choices) == 0 {
+				return nil, ErrContradiction
+			}
+			if backtracks == g.cfg.WFC.backtracks() {
+				return nil, fmt.Errorf("after %d backtracks: %w", backtracks, ErrContradiction)
+			}
+			backtracks++
+			c := choices[len(choices)-1]
+			choices = choices[:len(choices)-1]
+			w.undo(c.trail)
+			w.ban(c.cell, c.t)
+		}
+
+		// Collapse the undecided cell of lowest entropy, breaking ties at
+		// random. The entropy is in fixed point so every platform picks
+		// the same cell.
+		best, bestH, bestTie := -1, int64(0), int64(0)
+		for i, n := range w.count {
+			if n <= 1 {
+				continue
+			}
+			h := log2Fixed(w.sumW[i]) - w.sumWLog[i]/w.sumW[i]
+			tie := g.rng.Int63()
+			if best < 0 || h < bestH || (h == bestH && tie < bestTie) {
+				best, bestH, bestTie = i, h, tie
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		r := g.rng.Int63n(w.sumW[best])
+		pick := -1
+		for t := range nt {
+			if !w.allowed[best*nt+t] {
+				continue
+			}
+			pick = t
+			if r -= w.m.weights[t]; r < 0 {
+				break
+			}
+		}
+		choices = append(choices, wfcChoice{cell: best, t: pick, trail: len(w.trail)})
+		for t := range nt {
+			if t != pick && w.allowed[best*nt+t] {
+				w.ban(best, t)
+			}
+		}
+	}
+
+	n := w.m.n
+	rows := make([][]model.Tile, w.height)
+	for y := range w.height {
+		rows[y] = make([]model.Tile, w.width)
+		for x := range w.width {
+			dx, dy := 0, 0
+			if !w.periodic {
+				dx, dy = max(x-w.fmx+1, 0), max(y-w.fmy+1, 0)
+			}
+			i := (x - dx) + (y-dy)*w.fmx
+			for t := range nt {
+				if w.allowed[i*nt+t] {
+					rows[y][x] = w.m.patterns[t][dx+dy*n]
+					break
+				}
+			}
+		}
+	}
+	return rows, nil
+}
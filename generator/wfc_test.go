@@ -0,0 +1,92 @@
+package generator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// samplePatterns returns every n×n window of sample in the first sym of
+// its 8 orientations, keyed by their tiles in row order.
+func samplePatterns(sample [][]model.Tile, n, sym int) map[string]bool {
+	out := make(map[string]bool)
+	for y := 0; y+n <= len(sample); y++ {
+		for x := 0; x+n <= len(sample[0]); x++ {
+			// at reads the window at (x, y) turned by orientation k.
+			at := func(k, i, j int) model.Tile {
+				if k&1 == 1 {
+					i = n - 1 - i
+				}
+				for range k / 2 {
+					i, j = n-1-j, i
+				}
+				return sample[y+j][x+i]
+			}
+			for k := range sym {
+				var key []byte
+				for j := range n {
+					for i := range n {
+						key = append(key, byte(at(k, i, j)))
+					}
+				}
+				out[string(key)] = true
+			}
+		}
+	}
+	return out
+}
+
+func TestWFCPatternsFromSample(t *testing.T) {
+	tests := []struct {
+		width, height int32
+		sym           int
+	}{
+		{10, 8, 1},
+		{10, 8, 8},
+		{30, 15, 1},
+		{30, 15, 8},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx%d-sym%d", tt.width, tt.height, tt.sym), func(t *testing.T) {
+			cfg := testConfig(t, generator.ModeWFC)
+			cfg.Grid = model.Grid{MinX: 0, MaxX: tt.width - 1, MinY: 0, MaxY: tt.height - 1}
+			cfg.WFC.Symmetry = tt.sym
+			cfg.WFC.PeriodicInput = false
+			patterns := samplePatterns(cfg.WFC.Sample, 3, tt.sym)
+
+			done := 0
+			for seed := int64(1); seed <= 10; seed++ {
+				d, err := generator.New(cfg, seed).Generate(context.Background())
+				if errors.Is(err, generator.ErrContradiction) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				done++
+				for y := cfg.Grid.MinY; y+2 <= cfg.Grid.MaxY; y++ {
+					for x := cfg.Grid.MinX; x+2 <= cfg.Grid.MaxX; x++ {
+						// Sample rows run from the top, so read the
+						// window down from its top row.
+						var key []byte
+						for j := range int32(3) {
+							for i := range int32(3) {
+								key = append(key, byte(d.At(model.Cell{X: x + i, Y: y + 2 - j})))
+							}
+						}
+						if !patterns[string(key)] {
+							t.Errorf("seed %d: window at (%d, %d) is not in the sample", seed, x, y)
+						}
+					}
+				}
+			}
+			if done < 5 {
+				t.Errorf("only %d of 10 seeds finished without a contradiction", done)
+			}
+		})
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/mikegio27/proc-dungeons
 
 go 1.24.4
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	gopkg.in/yaml.v3 v3.0.1
+)
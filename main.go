@@ -1,36 +1,53 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"time"
-
-	"github.com/mikegio27/proc-dungeons/generator"
-	"github.com/mikegio27/proc-dungeons/model"
-	"github.com/mikegio27/proc-dungeons/render"
+	"io"
+	"os"
+	"os/signal"
 )
 
+const usage = `Usage: proc-dungeons <command> [flags]
+
+Commands:
+  generate   generate a dungeon and write it in the chosen format
+  render     generate a dungeon and draw only the map
+  stats      generate a dungeon and print room and tile statistics
+  validate   check a configuration, and with -generate the dungeon it makes
+
+Run "proc-dungeons <command> -h" for the flags of a command.
+`
+
 func main() {
-	fmt.Println("Procedurally generating dungeon...")
-	// Use current time as seed for randomness
-	// TODO: Allow user to specify seed via command-line argument
-	seed := time.Now().UnixNano()
-	fmt.Printf("Using seed: %d\n", seed)
-	gridX := int32(50)
-	gridY := int32(20)
-	maxRooms := 20
-	g := generator.New(generator.Config{
-		Grid:         model.Grid{MaxX: gridX, MaxY: gridY, MinX: -gridX, MinY: -gridY},
-		MaxRooms:     maxRooms,
-		CorridorW:    2,
-		CorridorBuff: 1,
-		RoomShapes: []model.RoomId{
-			model.Rectangle,
-			model.Circle,
-			model.Square,
-			model.Triangle,
-		},
-	}, seed)
-	d := g.Generate()
-	render.DrawDungeon(&d)
-	fmt.Printf("Rooms: %v\n", d.Rooms)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run dispatches to the subcommand named by args[0] and returns the process
+// exit code. With no arguments it behaves like "generate".
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return runGenerate(ctx, nil, stdout, stderr)
+	}
+
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "generate":
+		return runGenerate(ctx, rest, stdout, stderr)
+	case "render":
+		return runRender(ctx, rest, stdout, stderr)
+	case "stats":
+		return runStats(ctx, rest, stdout, stderr)
+	case "validate":
+		return runValidate(ctx, rest, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", cmd, usage)
+		return 2
+	}
 }
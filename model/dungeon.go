@@ -1,5 +1,11 @@
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
 type Dungeon struct {
 	Rooms  []Room
 	Tiles  []Tile
@@ -34,3 +40,79 @@ func (d *Dungeon) Set(c Cell, t Tile) {
 	}
 	d.Tiles[int(idx)] = t
 }
+
+// Fingerprint returns a hex SHA-256 digest of the grid, rooms with their
+// doors, tiles and starts. Two dungeons generated from the same seed and config must have
+// the same fingerprint on every run and platform, which makes it a cheap
+// way to confirm that a shared seed reproduces a reported map.
+func (d Dungeon) Fingerprint() string {
+	h := sha256.New()
+	put := func(vs ...int32) {
+		var buf [4]byte
+		for _, v := range vs {
+			binary.LittleEndian.PutUint32(buf[:], uint32(v))
+			h.Write(buf[:])
+		}
+	}
+
+	put(d.Grid.MinX, d.Grid.MaxX, d.Grid.MinY, d.Grid.MaxY)
+	put(int32(len(d.Rooms)))
+	for _, r := range d.Rooms {
+		put(r.TopLeft.X, r.TopLeft.Y, r.BottomRight.X, r.BottomRight.Y, int32(r.Shape))
+		put(int32(len(r.Doors)))
+		for _, c := range r.Doors {
+			put(c.X, c.Y)
+		}
+	}
+	put(int32(len(d.Tiles)))
+	for _, t := range d.Tiles {
+		h.Write([]byte{byte(t)})
+	}
+	put(int32(len(d.Starts)))
+	for _, s := range d.Starts {
+		put(s.X, s.Y)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Reachable flood-fills walkable tiles from every start and returns the set
+// of cells that can be reached. Starts are always included, even when their
+// own tile is not walkable.
+func (d Dungeon) Reachable() map[Cell]bool {
+	seen := make(map[Cell]bool)
+	var queue []Cell
+	for _, s := range d.Starts {
+		if !seen[s] {
+			seen[s] = true
+			queue = append(queue, s)
+		}
+	}
+
+	dirs := []Cell{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, di := range dirs {
+			n := Cell{X: c.X + di.X, Y: c.Y + di.Y}
+			if seen[n] || !d.At(n).Walkable() {
+				continue
+			}
+			seen[n] = true
+			queue = append(queue, n)
+		}
+	}
+	return seen
+}
+
+// VerticalPassage reports whether c has at least as many walkable
+// neighbours above and below as left and right, which for a door means the
+// way through it runs along the Y axis.
+func (d Dungeon) VerticalPassage(c Cell) bool {
+	walk := func(dx, dy int32) int {
+		if d.At(Cell{X: c.X + dx, Y: c.Y + dy}).Walkable() {
+			return 1
+		}
+		return 0
+	}
+	return walk(0, 1)+walk(0, -1) >= walk(1, 0)+walk(-1, 0)
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the Dungeon JSON encoding written by
+// MarshalJSON. UnmarshalJSON rejects newer versions.
+const SchemaVersion = 1
+
+// maxTiles is the largest grid UnmarshalJSON accepts, so a bad header
+// cannot make it allocate without bound.
+const maxTiles = 1 << 26
+
+// tileEncoding names the only tile encoding: run-length pairs of a uvarint
+// count and a tile byte, in row-major order, then standard base64.
+const tileEncoding = "rle-base64"
+
+type dungeonJSON struct {
+	Version      int        `json:"version"`
+	Grid         gridJSON   `json:"grid"`
+	Rooms        []roomJSON `json:"rooms"`
+	TileEncoding string     `json:"tile_encoding"`
+	Tiles        string     `json:"tiles"`
+	Starts       []cellJSON `json:"starts"`
+}
+
+type gridJSON struct {
+	MinX int32 `json:"min_x"`
+	MaxX int32 `json:"max_x"`
+	MinY int32 `json:"min_y"`
+	MaxY int32 `json:"max_y"`
+}
+
+type roomJSON struct {
+	Shape       RoomId     `json:"shape"`
+	TopLeft     cellJSON   `json:"top_left"`
+	BottomRight cellJSON   `json:"bottom_right"`
+	Doors       []cellJSON `json:"doors,omitempty"`
+}
+
+type cellJSON struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+}
+
+// MarshalJSON implements json.Marshaler. Rooms are written with their
+// shape names and the tiles as a run-length encoded base64 string, so a
+// mostly empty map stays small.
+func (d Dungeon) MarshalJSON() ([]byte, error) {
+	out := dungeonJSON{
+		Version:      SchemaVersion,
+		Grid:         gridJSON{MinX: d.Grid.MinX, MaxX: d.Grid.MaxX, MinY: d.Grid.MinY, MaxY: d.Grid.MaxY},
+		Rooms:        make([]roomJSON, len(d.Rooms)),
+		TileEncoding: tileEncoding,
+		Tiles:        base64.StdEncoding.EncodeToString(encodeTiles(d.Tiles)),
+		Starts:       make([]cellJSON, len(d.Starts)),
+	}
+	for i, r := range d.Rooms {
+		out.Rooms[i] = roomJSON{Shape: r.Shape, TopLeft: cellJSON(r.TopLeft), BottomRight: cellJSON(r.BottomRight)}
+		for _, c := range r.Doors {
+			out.Rooms[i].Doors = append(out.Rooms[i].Doors, cellJSON(c))
+		}
+	}
+	for i, s := range d.Starts {
+		out.Starts[i] = cellJSON(s)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It checks the schema version
+// and that the tiles cover the grid exactly.
+func (d *Dungeon) UnmarshalJSON(b []byte) error {
+	var in dungeonJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	switch {
+	case in.Version == 0:
+		return errors.New("dungeon: version is required")
+	case in.Version > SchemaVersion:
+		return fmt.Errorf("dungeon: version %d is newer than the supported version %d", in.Version, SchemaVersion)
+	}
+	if in.TileEncoding != tileEncoding {
+		return fmt.Errorf("dungeon: unknown tile encoding %q", in.TileEncoding)
+	}
+
+	// Size the grid in int64: int32 bounds far apart overflow Width and
+	// Height.
+	width := int64(in.Grid.MaxX) - int64(in.Grid.MinX) + 1
+	height := int64(in.Grid.MaxY) - int64(in.Grid.MinY) + 1
+	if width < 1 || height < 1 {
+		return fmt.Errorf("dungeon: empty grid %+v", in.Grid)
+	}
+	if width > maxTiles || height > maxTiles || width*height > maxTiles {
+		return fmt.Errorf("dungeon: grid of %dx%d tiles is larger than %d tiles", width, height, maxTiles)
+	}
+	grid := Grid{MinX: in.Grid.MinX, MaxX: in.Grid.MaxX, MinY: in.Grid.MinY, MaxY: in.Grid.MaxY}
+	raw, err := base64.StdEncoding.DecodeString(in.Tiles)
+	if err != nil {
+		return fmt.Errorf("dungeon: tiles: %w", err)
+	}
+	tiles, err := decodeTiles(raw, int(width*height))
+	if err != nil {
+		return fmt.Errorf("dungeon: tiles: %w", err)
+	}
+
+	out := Dungeon{Grid: grid, Tiles: tiles}
+	for _, r := range in.Rooms {
+		room := Room{TopLeft: Cell(r.TopLeft), BottomRight: Cell(r.BottomRight), Shape: r.Shape}
+		for _, c := range r.Doors {
+			room.Doors = append(room.Doors, Cell(c))
+		}
+		out.Rooms = append(out.Rooms, room)
+	}
+	for _, s := range in.Starts {
+		out.Starts = append(out.Starts, Cell(s))
+	}
+	*d = out
+	return nil
+}
+
+func encodeTiles(tiles []Tile) []byte {
+	var out []byte
+	for i := 0; i < len(tiles); {
+		j := i + 1
+		for j < len(tiles) && tiles[j] == tiles[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i))
+		out = append(out, byte(tiles[i]))
+		i = j
+	}
+	return out
+}
+
+// decodeTiles reverses encodeTiles and fails unless the runs add up to
+// exactly n tiles. The tiles grow with the runs rather than being sized
+// from n up front.
+func decodeTiles(b []byte, n int) ([]Tile, error) {
+	var tiles []Tile
+	for len(b) > 0 {
+		run, k := binary.Uvarint(b)
+		if k <= 0 || k >= len(b) {
+			return nil, errors.New("truncated run")
+		}
+		if run == 0 || run > uint64(n-len(tiles)) {
+			return nil, fmt.Errorf("run of %d tiles does not fit the %d tile grid", run, n)
+		}
+		t := Tile(b[k])
+		if t > TileWall {
+			return nil, fmt.Errorf("unknown tile %d", t)
+		}
+		for range run {
+			tiles = append(tiles, t)
+		}
+		b = b[k+1:]
+	}
+	if len(tiles) != n {
+		return nil, fmt.Errorf("have %d tiles, want %d", len(tiles), n)
+	}
+	return tiles, nil
+}
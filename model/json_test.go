@@ -0,0 +1,119 @@
+package model_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// testDungeon returns a small dungeon with every tile, a run longer than
+// one varint byte, rooms with and without doors, and a start.
+func testDungeon() model.Dungeon {
+	d := model.NewDungeon(model.Grid{MinX: -20, MaxX: 19, MinY: -5, MaxY: 6})
+	room := model.Room{
+		Shape:       model.Rectangle,
+		TopLeft:     model.Cell{X: -10, Y: -2},
+		BottomRight: model.Cell{X: -6, Y: 1},
+		Doors:       []model.Cell{{X: -6, Y: 0}},
+	}
+	for y := room.TopLeft.Y - 1; y <= room.BottomRight.Y+1; y++ {
+		for x := room.TopLeft.X - 1; x <= room.BottomRight.X+1; x++ {
+			d.Set(model.Cell{X: x, Y: y}, model.TileWall)
+		}
+	}
+	for y := room.TopLeft.Y; y <= room.BottomRight.Y; y++ {
+		for x := room.TopLeft.X; x <= room.BottomRight.X; x++ {
+			d.Set(model.Cell{X: x, Y: y}, model.TileRoomFloor)
+		}
+	}
+	d.Set(room.Doors[0], model.TileDoor)
+	for x := int32(-5); x <= 19; x++ {
+		d.Set(model.Cell{X: x, Y: 0}, model.TileCorridor)
+	}
+	d.Rooms = []model.Room{
+		room,
+		{Shape: model.Cave, TopLeft: model.Cell{X: 5, Y: 3}, BottomRight: model.Cell{X: 8, Y: 5}},
+	}
+	d.Starts = []model.Cell{{X: 19, Y: 0}}
+	return d
+}
+
+func TestDungeonJSONRoundTrip(t *testing.T) {
+	want := testDungeon()
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got model.Dungeon
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the dungeon:\ngot  %+v\nwant %+v", got, want)
+	}
+	if got.Fingerprint() != want.Fingerprint() {
+		t.Errorf("Fingerprint = %s, want %s", got.Fingerprint(), want.Fingerprint())
+	}
+
+	// A second pass must write the same bytes.
+	again, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(b) {
+		t.Errorf("re-encoding differs:\n%s\n%s", again, b)
+	}
+}
+
+func TestDungeonJSONRejects(t *testing.T) {
+	good, err := json.Marshal(testDungeon())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// edit decodes the good encoding, changes it and encodes it again.
+	edit := func(fn func(m map[string]any)) string {
+		var m map[string]any
+		if err := json.Unmarshal(good, &m); err != nil {
+			t.Fatal(err)
+		}
+		fn(m)
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	grid := func(minX, maxX, minY, maxY int64) func(m map[string]any) {
+		return func(m map[string]any) {
+			m["grid"] = map[string]any{"min_x": minX, "max_x": maxX, "min_y": minY, "max_y": maxY}
+		}
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no version", edit(func(m map[string]any) { delete(m, "version") }), "version is required"},
+		{"newer version", edit(func(m map[string]any) { m["version"] = model.SchemaVersion + 1 }), "newer than"},
+		{"encoding", edit(func(m map[string]any) { m["tile_encoding"] = "raw" }), "unknown tile encoding"},
+		{"empty grid", edit(grid(5, 4, 0, 0)), "empty grid"},
+		{"huge grid", edit(grid(-100000, 100000, -100000, 100000)), "larger than"},
+		{"overflowing grid", edit(grid(-2e9, 2e9, -2e9, 2e9)), "larger than"},
+		{"short tiles", edit(grid(-20, 19, -5, 7)), "want"},
+		{"bad base64", edit(func(m map[string]any) { m["tiles"] = "!!" }), "tiles"},
+		{"unknown tile", edit(func(m map[string]any) { m["tiles"] = "AQk=" }), "unknown tile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d model.Dungeon
+			err := json.Unmarshal([]byte(tt.in), &d)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Unmarshal error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
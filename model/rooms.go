@@ -1,11 +1,19 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+)
+
 type RoomId int
 
 type Room struct {
 	TopLeft     Cell
 	BottomRight Cell
 	Shape       RoomId
+	// Doors lists the cells of the room's doors. It is empty for caves and
+	// in dungeons saved before doors were recorded.
+	Doors []Cell
 }
 
 const (
@@ -13,6 +21,9 @@ const (
 	Circle
 	Square
 	Triangle
+	// Cave is an irregular region made by cellular automata. Its cells
+	// are the floor tiles inside its bounding box rather than a shape.
+	Cave
 )
 
 var shapeName = map[RoomId]string{
@@ -20,6 +31,7 @@ var shapeName = map[RoomId]string{
 	Circle:    "Circle",
 	Square:    "Square",
 	Triangle:  "Triangle",
+	Cave:      "Cave",
 }
 
 // String implements fmt.Stringer for RoomId, returning the human-readable
@@ -30,3 +42,60 @@ func (id RoomId) String() string {
 	}
 	return "Unknown"
 }
+
+// Valid reports whether id names a known shape.
+func (id RoomId) Valid() bool {
+	_, ok := shapeName[id]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler so shapes are written by
+// name in config and save files.
+func (id RoomId) MarshalText() ([]byte, error) {
+	if !id.Valid() {
+		return nil, fmt.Errorf("unknown room shape %d", int(id))
+	}
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting any shape
+// name understood by ParseRoomId.
+func (id *RoomId) UnmarshalText(b []byte) error {
+	v, ok := ParseRoomId(string(b))
+	if !ok {
+		return fmt.Errorf("unknown room shape %q", string(b))
+	}
+	*id = v
+	return nil
+}
+
+// ParseRoomId returns the RoomId whose name matches s, ignoring case.
+func ParseRoomId(s string) (RoomId, bool) {
+	for id, name := range shapeName {
+		if strings.EqualFold(name, s) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Contains reports whether c lies inside the room's bounding box.
+func (r Room) Contains(c Cell) bool {
+	return c.X >= r.TopLeft.X && c.X <= r.BottomRight.X &&
+		c.Y >= r.TopLeft.Y && c.Y <= r.BottomRight.Y
+}
+
+// Overlaps reports whether the bounding boxes of r and o share a cell.
+func (r Room) Overlaps(o Room) bool {
+	return r.TopLeft.X <= o.BottomRight.X && o.TopLeft.X <= r.BottomRight.X &&
+		r.TopLeft.Y <= o.BottomRight.Y && o.TopLeft.Y <= r.BottomRight.Y
+}
+
+// eachBoxCell calls fn for every cell of the room's bounding box.
+func (r Room) eachBoxCell(fn func(Cell)) {
+	for y := r.TopLeft.Y; y <= r.BottomRight.Y; y++ {
+		for x := r.TopLeft.X; x <= r.BottomRight.X; x++ {
+			fn(Cell{X: x, Y: y})
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 // Tile represents the type of a cell in the dungeon grid.
 type Tile uint8
 
@@ -21,20 +23,34 @@ func (t Tile) String() string {
 		return "Corridor"
 	case TileDoor:
 		return "Door"
+	case TileWall:
+		return "Wall"
 	default:
 		return "Unknown"
 	}
 }
 
-// Rune is an ASCII glyph for a simple renderer.
+// ParseTile returns the Tile whose name (as returned by String) matches s,
+// ignoring case.
+func ParseTile(s string) (Tile, bool) {
+	for t := TileEmpty; t <= TileWall; t++ {
+		if strings.EqualFold(t.String(), s) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// Rune is an ASCII glyph for a simple renderer, matching the legend in the
+// README.
 func (t Tile) Rune() rune {
 	switch t {
 	case TileEmpty:
-		return '_'
+		return ' '
 	case TileRoomFloor:
 		return '.'
 	case TileCorridor:
-		return ' '
+		return '#'
 	case TileDoor:
 		return '+'
 	case TileWall:
@@ -43,3 +59,18 @@ func (t Tile) Rune() rune {
 		return '?'
 	}
 }
+
+// ParseRune returns the Tile whose Rune is r.
+func ParseRune(r rune) (Tile, bool) {
+	for t := TileEmpty; t <= TileWall; t++ {
+		if t.Rune() == r {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// Walkable reports whether a character can stand on the tile.
+func (t Tile) Walkable() bool {
+	return t == TileRoomFloor || t == TileCorridor || t == TileDoor
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Invariant names a rule checked by Dungeon.Validate.
+type Invariant string
+
+const (
+	// InvRoomBounds: every room floor and door tile lies inside a room's
+	// bounding box.
+	InvRoomBounds Invariant = "room-bounds"
+	// InvRoomOverlap: room bounding boxes do not overlap. Cave rooms are
+	// exempt.
+	InvRoomOverlap Invariant = "room-overlap"
+	// InvRoomDoor: every room except a cave has a door next to a corridor,
+	// and every cell in a room's Doors is a door inside its bounding box.
+	InvRoomDoor Invariant = "room-door"
+	// InvCorridorTouch: corridors only meet room floor through a door.
+	// Corridor cells within one tile of a door are exempt, since that is
+	// where a corridor plugs into its room, and so is cave floor.
+	InvCorridorTouch Invariant = "corridor-touch"
+	// InvWalls: no room floor tile borders empty space.
+	InvWalls Invariant = "walls"
+	// InvStarts: every start lies on the grid boundary.
+	InvStarts Invariant = "starts"
+	// InvTiles: Tiles has one entry per grid cell.
+	InvTiles Invariant = "tiles"
+)
+
+// Violation describes one broken invariant. Room is the index of the room
+// involved, or -1; Cell is the offending cell, when there is one.
+type Violation struct {
+	Invariant Invariant
+	Room      int
+	Cell      Cell
+	Msg       string
+}
+
+func (v Violation) Error() string {
+	return string(v.Invariant) + ": " + v.Msg
+}
+
+// Violations collects every Violation found in a Dungeon.
+type Violations []Violation
+
+func (vs Violations) Error() string {
+	msgs := make([]string, len(vs))
+	for i, v := range vs {
+		msgs[i] = v.Error()
+	}
+	return "invalid dungeon: " + strings.Join(msgs, "; ")
+}
+
+var dirs4 = []Cell{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
+
+// Validate checks the structural invariants of d and returns every
+// violation as Violations, or nil when d is sound. It only looks at the
+// tiles, rooms and starts, so it works on loaded dungeons as well as
+// freshly generated ones.
+func (d Dungeon) Validate() error {
+	var vs Violations
+	add := func(inv Invariant, room int, c Cell, format string, args ...any) {
+		vs = append(vs, Violation{Invariant: inv, Room: room, Cell: c, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if want := int(d.Grid.Width() * d.Grid.Height()); len(d.Tiles) != want {
+		add(InvTiles, -1, Cell{}, "have %d tiles, want %d for the grid", len(d.Tiles), want)
+		return vs
+	}
+
+	for i, r := range d.Rooms {
+		for j := i + 1; j < len(d.Rooms); j++ {
+			// Cave bounding boxes are loose and may hold other rooms.
+			if r.Shape == Cave || d.Rooms[j].Shape == Cave {
+				continue
+			}
+			if r.Overlaps(d.Rooms[j]) {
+				add(InvRoomOverlap, i, r.TopLeft, "room %d overlaps room %d", i, j)
+			}
+		}
+	}
+
+	for i, r := range d.Rooms {
+		for _, c := range r.Doors {
+			if !r.Contains(c) || d.At(c) != TileDoor {
+				add(InvRoomDoor, i, c, "room %d lists a door at %v that is not a door of the room", i, c)
+			}
+		}
+		if r.Shape == Cave {
+			// Caves are open to the corridors that reach them.
+			continue
+		}
+		hasDoor := false
+		r.eachBoxCell(func(c Cell) {
+			if d.At(c) == TileDoor && d.touches(c, TileCorridor) {
+				hasDoor = true
+			}
+		})
+		if !hasDoor {
+			add(InvRoomDoor, i, r.TopLeft, "room %d (%s at %v) has no door next to a corridor", i, r.Shape, r.TopLeft)
+		}
+	}
+
+	for y := d.Grid.MinY; y <= d.Grid.MaxY; y++ {
+		for x := d.Grid.MinX; x <= d.Grid.MaxX; x++ {
+			c := Cell{X: x, Y: y}
+			switch d.At(c) {
+			case TileRoomFloor:
+				if d.roomAt(c) < 0 {
+					add(InvRoomBounds, -1, c, "floor at %v is outside every room", c)
+				}
+				if !d.inCave(c) && d.touchesStrayCorridor(c) {
+					add(InvCorridorTouch, d.roomAt(c), c, "floor at %v touches a corridor", c)
+				}
+				if d.touches(c, TileEmpty) {
+					add(InvWalls, d.roomAt(c), c, "floor at %v has no wall", c)
+				}
+			case TileDoor:
+				if d.roomAt(c) < 0 {
+					add(InvRoomBounds, -1, c, "door at %v is outside every room", c)
+				}
+			}
+		}
+	}
+
+	for _, s := range d.Starts {
+		if !d.Grid.OnGridBoundary(s) {
+			add(InvStarts, -1, s, "start %v is not on the grid boundary", s)
+		}
+	}
+
+	if len(vs) == 0 {
+		return nil
+	}
+	return vs
+}
+
+// touches reports whether any in-bounds 4-neighbour of c holds tile t.
+func (d Dungeon) touches(c Cell, t Tile) bool {
+	for _, di := range dirs4 {
+		n := Cell{X: c.X + di.X, Y: c.Y + di.Y}
+		if d.InBounds(n) && d.At(n) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// touchesStrayCorridor reports whether c has a corridor 4-neighbour that is
+// not within one tile of a door.
+func (d Dungeon) touchesStrayCorridor(c Cell) bool {
+	for _, di := range dirs4 {
+		n := Cell{X: c.X + di.X, Y: c.Y + di.Y}
+		if d.InBounds(n) && d.At(n) == TileCorridor && !d.nearDoor(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// nearDoor reports whether a door lies within Chebyshev distance 1 of c.
+func (d Dungeon) nearDoor(c Cell) bool {
+	for y := c.Y - 1; y <= c.Y+1; y++ {
+		for x := c.X - 1; x <= c.X+1; x++ {
+			if d.At(Cell{X: x, Y: y}) == TileDoor {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inCave reports whether c lies inside the bounding box of a Cave room.
+func (d Dungeon) inCave(c Cell) bool {
+	for _, r := range d.Rooms {
+		if r.Shape == Cave && r.Contains(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// roomAt returns the index of the first room whose bounding box holds c,
+// or -1.
+func (d Dungeon) roomAt(c Cell) int {
+	for i, r := range d.Rooms {
+		if r.Contains(c) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,257 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// ColorMode selects the ANSI escape sequences a Renderer emits.
+type ColorMode int
+
+const (
+	// ColorNone writes plain glyphs.
+	ColorNone ColorMode = iota
+	// Color16 uses the 16 standard terminal colors.
+	Color16
+	// Color256 uses the xterm 256-color palette.
+	Color256
+	// ColorTrue uses 24-bit color.
+	ColorTrue
+)
+
+var colorModeName = map[ColorMode]string{
+	ColorNone: "none",
+	Color16:   "16",
+	Color256:  "256",
+	ColorTrue: "truecolor",
+}
+
+func (m ColorMode) String() string {
+	if name, ok := colorModeName[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseColorMode returns the ColorMode named s, ignoring case.
+func ParseColorMode(s string) (ColorMode, bool) {
+	for m, name := range colorModeName {
+		if strings.EqualFold(name, s) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// DetectColor picks the richest ColorMode w supports. It returns ColorNone
+// when NO_COLOR is set, when w is not a terminal or when TERM is "dumb";
+// otherwise COLORTERM and TERM decide between truecolor, 256 and 16 colors.
+func DetectColor(w io.Writer) ColorMode {
+	if os.Getenv("NO_COLOR") != "" {
+		return ColorNone
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return ColorNone
+	}
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return ColorNone
+	}
+
+	term := os.Getenv("TERM")
+	switch ct := os.Getenv("COLORTERM"); {
+	case term == "dumb":
+		return ColorNone
+	case ct == "truecolor" || ct == "24bit":
+		return ColorTrue
+	case strings.Contains(term, "256color"):
+		return Color256
+	}
+	return Color16
+}
+
+// Color is a 24-bit RGB color. Renderers in 16 and 256 color mode use the
+// nearest palette entry.
+type Color struct {
+	R, G, B uint8
+}
+
+// ParseColor parses a "#rrggbb" or "rrggbb" hex color.
+func ParseColor(s string) (Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return Color{}, fmt.Errorf("bad color %q, want #rrggbb", s)
+	}
+	return Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+func (c Color) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Theme is the palette of a color Renderer. Starts are drawn in Start with
+// reverse video so they stand out on any background.
+type Theme struct {
+	Tiles map[model.Tile]Color
+	Start Color
+}
+
+// Themes are the built-in palettes, by name.
+var Themes = map[string]Theme{
+	"default": {
+		Tiles: map[model.Tile]Color{
+			model.TileEmpty:     {R: 0x30, G: 0x30, B: 0x30},
+			model.TileRoomFloor: {R: 0xc8, G: 0xb4, B: 0x8c},
+			model.TileCorridor:  {R: 0x80, G: 0x80, B: 0x80},
+			model.TileDoor:      {R: 0xe0, G: 0x8a, B: 0x1e},
+			model.TileWall:      {R: 0x5a, G: 0x6e, B: 0x96},
+		},
+		Start: Color{R: 0x4c, G: 0xd0, B: 0x4c},
+	},
+	"high-contrast": {
+		Tiles: map[model.Tile]Color{
+			model.TileEmpty:     {R: 0x00, G: 0x00, B: 0x00},
+			model.TileRoomFloor: {R: 0xff, G: 0xff, B: 0xff},
+			model.TileCorridor:  {R: 0xff, G: 0xff, B: 0x00},
+			model.TileDoor:      {R: 0xff, G: 0x00, B: 0x00},
+			model.TileWall:      {R: 0x00, G: 0xff, B: 0xff},
+		},
+		Start: Color{R: 0x00, G: 0xff, B: 0x00},
+	},
+}
+
+// ThemeNames returns the names of the built-in themes in sorted order.
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseTileColors parses a comma-separated list of tile=#rrggbb pairs into
+// overrides for Theme.Tiles. The tile name "Start" sets Theme.Start.
+func ParseTileColors(s string, theme *Theme) error {
+	tiles := make(map[model.Tile]Color, len(theme.Tiles))
+	for t, c := range theme.Tiles {
+		tiles[t] = c
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("want tile=#rrggbb, got %q", pair)
+		}
+		c, err := ParseColor(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, "start") {
+			theme.Start = c
+			continue
+		}
+		t, ok := model.ParseTile(name)
+		if !ok {
+			return fmt.Errorf("unknown tile %q", name)
+		}
+		tiles[t] = c
+	}
+	theme.Tiles = tiles
+	return nil
+}
+
+// sgr returns the escape sequence that sets the foreground to c in mode.
+func sgr(mode ColorMode, c Color, reverse bool) string {
+	var code string
+	switch mode {
+	case Color16:
+		code = strconv.Itoa(ansi16Code(c))
+	case Color256:
+		code = "38;5;" + strconv.Itoa(ansi256Index(c))
+	case ColorTrue:
+		code = fmt.Sprintf("38;2;%d;%d;%d", c.R, c.G, c.B)
+	default:
+		return ""
+	}
+	if reverse {
+		code = "1;7;" + code
+	}
+	return "\x1b[0;" + code + "m"
+}
+
+const sgrReset = "\x1b[0m"
+
+// ansi16 is the xterm rendering of the 16 standard colors, in SGR order
+// 30-37 then 90-97.
+var ansi16 = [16]Color{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+func ansi16Code(c Color) int {
+	best, bestDist := 0, -1
+	for i, p := range ansi16 {
+		if d := colorDist(c, p); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	if best < 8 {
+		return 30 + best
+	}
+	return 90 + best - 8
+}
+
+// cubeLevels are the channel values of the 6x6x6 cube in the 256-color
+// palette.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// ansi256Index returns the nearest entry of the color cube (16-231) or the
+// grayscale ramp (232-255).
+func ansi256Index(c Color) int {
+	nearest := func(v uint8) int {
+		best := 0
+		for i, l := range cubeLevels {
+			if abs(int(v)-l) < abs(int(v)-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := nearest(c.R), nearest(c.G), nearest(c.B)
+	cube := Color{R: uint8(cubeLevels[r]), G: uint8(cubeLevels[g]), B: uint8(cubeLevels[b])}
+
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	step := min(max((avg-8+5)/10, 0), 23)
+	gv := uint8(8 + 10*step)
+	gray := Color{R: gv, G: gv, B: gv}
+
+	if colorDist(c, gray) < colorDist(c, cube) {
+		return 232 + step
+	}
+	return 16 + 36*r + 6*g + b
+}
+
+func colorDist(a, b Color) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,90 @@
+package render_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+var sgrPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestRenderColor(t *testing.T) {
+	d := testDungeon(t)
+	theme := render.Theme{
+		Tiles: map[model.Tile]render.Color{
+			model.TileRoomFloor: {R: 0xff},
+			model.TileCorridor:  {R: 0x80, G: 0x80, B: 0x80},
+			model.TileWall:      {B: 0xff},
+		},
+		Start: render.Color{G: 0xff},
+	}
+	// Each mode's escape for the floor, corridor and start colors; doors
+	// and empty tiles are missing from the theme and drawn plain.
+	tests := []struct {
+		mode                   render.ColorMode
+		floor, corridor, start string
+	}{
+		{render.Color16, "\x1b[0;91m", "\x1b[0;90m", "\x1b[0;1;7;92m"},
+		{render.Color256, "\x1b[0;38;5;196m", "\x1b[0;38;5;244m", "\x1b[0;1;7;38;5;46m"},
+		{render.ColorTrue, "\x1b[0;38;2;255;0;0m", "\x1b[0;38;2;128;128;128m", "\x1b[0;1;7;38;2;0;255;0m"},
+	}
+	plain := render.New(render.Options{}).String(&d)
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			got := render.New(render.Options{Color: tt.mode, Theme: &theme}).String(&d)
+			if stripped := sgrPattern.ReplaceAllString(got, ""); stripped != plain {
+				t.Errorf("without escapes got:\n%s\nwant:\n%s", stripped, plain)
+			}
+			// The second row is wall, two floors, a door, corridor and the
+			// start; a style is only written when it changes.
+			row := strings.Split(got, "\n")[1]
+			want := tt.floor + ".." + "\x1b[0m+" + tt.corridor + "##" + tt.start + "*\x1b[0m"
+			if !strings.HasSuffix(row, want) {
+				t.Errorf("row = %q, want it to end in %q", row, want)
+			}
+			for i, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+				if esc := sgrPattern.FindAllString(line, -1); len(esc) > 0 && esc[len(esc)-1] != "\x1b[0m" {
+					t.Errorf("line %d = %q does not reset its color", i, line)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if got := render.DetectColor(&bytes.Buffer{}); got != render.ColorNone {
+		t.Errorf("DetectColor(buffer) = %v, want none", got)
+	}
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("COLORTERM", "truecolor")
+	if got := render.DetectColor(&bytes.Buffer{}); got != render.ColorNone {
+		t.Errorf("DetectColor with NO_COLOR = %v, want none", got)
+	}
+}
+
+func TestParseTileColors(t *testing.T) {
+	theme := render.Themes["default"]
+	if err := render.ParseTileColors("Door=#ff8800, start=00ff00", &theme); err != nil {
+		t.Fatal(err)
+	}
+	if got := theme.Tiles[model.TileDoor]; got != (render.Color{R: 0xff, G: 0x88}) {
+		t.Errorf("door = %v, want #ff8800", got)
+	}
+	if theme.Start != (render.Color{G: 0xff}) {
+		t.Errorf("start = %v, want #00ff00", theme.Start)
+	}
+	if render.Themes["default"].Tiles[model.TileDoor] == theme.Tiles[model.TileDoor] {
+		t.Error("ParseTileColors changed the built-in theme")
+	}
+	for _, bad := range []string{"Door", "Door=#ff88", "Lava=#ff0000"} {
+		theme := render.Themes["default"]
+		if err := render.ParseTileColors(bad, &theme); err == nil {
+			t.Errorf("ParseTileColors(%q) succeeded", bad)
+		}
+	}
+}
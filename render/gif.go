@@ -0,0 +1,140 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// finalDelay is how long the last frame of an Animation is held, in
+// hundredths of a second, so the finished dungeon can be seen before the
+// animation loops.
+const finalDelay = 300
+
+// Animation collects frames of a dungeon, usually one per generation step,
+// and encodes them as an animated GIF. Each frame is drawn with Image and
+// only the area that changed since the previous frame is stored, so long
+// recordings stay small.
+type Animation struct {
+	opts    ImageOptions
+	delay   int
+	palette color.Palette
+
+	gif  gif.GIF
+	prev *image.Paletted
+}
+
+// NewAnimation returns an empty Animation whose frames are drawn with opts
+// and shown for delay each.
+func NewAnimation(opts ImageOptions, delay time.Duration) *Animation {
+	theme := opts.Theme
+	if theme == nil {
+		t := Themes["default"]
+		theme = &t
+	}
+	opts.Theme = theme
+
+	// The palette holds exactly the colors Image can draw.
+	pal := color.Palette{color.Black}
+	add := func(c color.Color) {
+		for _, p := range pal {
+			if p == c {
+				return
+			}
+		}
+		pal = append(pal, c)
+	}
+	for _, t := range []model.Tile{model.TileEmpty, model.TileRoomFloor, model.TileCorridor, model.TileDoor, model.TileWall} {
+		if c, ok := theme.Tiles[t]; ok {
+			add(c.rgba())
+		}
+	}
+	add(theme.Start.rgba())
+	add(opts.GridColor.rgba())
+	add(opts.OutlineColor.rgba())
+
+	return &Animation{
+		opts:    opts,
+		delay:   max(1, int(delay/(10*time.Millisecond))),
+		palette: pal,
+	}
+}
+
+// AddFrame draws d as the next frame. A frame identical to the previous
+// one extends that frame's delay instead.
+func (a *Animation) AddFrame(d *model.Dungeon) {
+	src := Image(d, a.opts)
+	img := image.NewPaletted(src.Bounds(), a.palette)
+	draw.Draw(img, img.Bounds(), src, image.Point{}, draw.Src)
+
+	r := img.Bounds()
+	if a.prev != nil {
+		if a.prev.Bounds() != img.Bounds() {
+			// A different grid size cannot be drawn over the previous
+			// frame; start a new animation instead.
+			a.gif, a.prev = gif.GIF{}, nil
+		} else {
+			r = changed(a.prev, img)
+		}
+	}
+	a.prev = img
+	if r.Empty() {
+		if n := len(a.gif.Delay); n > 0 {
+			a.gif.Delay[n-1] += a.delay
+		}
+		return
+	}
+
+	a.gif.Image = append(a.gif.Image, img.SubImage(r).(*image.Paletted))
+	a.gif.Delay = append(a.gif.Delay, a.delay)
+	a.gif.Disposal = append(a.gif.Disposal, gif.DisposalNone)
+}
+
+// Len returns the number of frames stored so far.
+func (a *Animation) Len() int {
+	return len(a.gif.Image)
+}
+
+// WriteGIF encodes the frames as a looping GIF to w. The last frame is
+// held for a few seconds.
+func (a *Animation) WriteGIF(w io.Writer) error {
+	if len(a.gif.Image) == 0 {
+		return errors.New("encode gif: no frames")
+	}
+	out := a.gif
+	out.Delay = append([]int(nil), a.gif.Delay...)
+	out.Delay[len(out.Delay)-1] = max(out.Delay[len(out.Delay)-1], finalDelay)
+	out.Config = image.Config{
+		ColorModel: a.palette,
+		Width:      a.prev.Bounds().Dx(),
+		Height:     a.prev.Bounds().Dy(),
+	}
+	if err := gif.EncodeAll(w, &out); err != nil {
+		return fmt.Errorf("encode gif: %w", err)
+	}
+	return nil
+}
+
+// changed returns the smallest rectangle holding every pixel that differs
+// between a and b, which have the same bounds.
+func changed(a, b *image.Paletted) image.Rectangle {
+	var r image.Rectangle
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ai := a.PixOffset(bounds.Min.X, y)
+		bi := b.PixOffset(bounds.Min.X, y)
+		for x := 0; x < bounds.Dx(); x++ {
+			if a.Pix[ai+x] != b.Pix[bi+x] {
+				r = r.Union(image.Rect(bounds.Min.X+x, y, bounds.Min.X+x+1, y+1))
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,59 @@
+package render_test
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+func TestAnimation(t *testing.T) {
+	d := testDungeon(t)
+	const s = 4
+	a := render.NewAnimation(render.ImageOptions{Scale: s}, 50*time.Millisecond)
+	if err := a.WriteGIF(&bytes.Buffer{}); err == nil {
+		t.Error("WriteGIF with no frames succeeded")
+	}
+
+	a.AddFrame(&d)
+	a.AddFrame(&d) // unchanged, so it only holds the first frame longer
+	changed := model.Cell{X: 5, Y: 1}
+	d.Set(changed, model.TileCorridor)
+	a.AddFrame(&d)
+	if a.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", a.Len())
+	}
+
+	var buf bytes.Buffer
+	if err := a.WriteGIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 2 {
+		t.Fatalf("decoded %d frames, want 2", len(g.Image))
+	}
+	if g.Config.Width != 7*s || g.Config.Height != 4*s {
+		t.Errorf("gif is %dx%d, want %dx%d", g.Config.Width, g.Config.Height, 7*s, 4*s)
+	}
+	if got, want := g.Image[0].Bounds(), image.Rect(0, 0, 7*s, 4*s); got != want {
+		t.Errorf("first frame = %v, want the whole map %v", got, want)
+	}
+	// Later frames only hold the tiles that changed.
+	x, y := int(changed.X-d.Grid.MinX)*s, int(d.Grid.MaxY-changed.Y)*s
+	if got, want := g.Image[1].Bounds(), image.Rect(x, y, x+s, y+s); got != want {
+		t.Errorf("second frame = %v, want the changed tile %v", got, want)
+	}
+	if g.Delay[0] != 10 {
+		t.Errorf("first delay = %d, want 10 for two identical frames", g.Delay[0])
+	}
+	if g.Delay[1] < 100 {
+		t.Errorf("last delay = %d, want it held for seconds", g.Delay[1])
+	}
+}
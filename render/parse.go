@@ -0,0 +1,80 @@
+package render
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// ParseASCII reads a map drawn by Render, or by hand in the same glyphs,
+// and returns its tiles one row per line from the top. It uses the glyphs
+// of opts, and reads start glyphs as corridor. Whether every glyph is
+// followed by a space is detected, so opts.Wide is ignored, and a border
+// is kept as the wall tiles it shows.
+//
+// Lines holding anything but map glyphs, such as the seed line printed by
+// the generate command, are skipped; the map is the first run of lines made
+// only of glyphs. Shorter lines are padded with empty tiles. Colored output
+// cannot be read.
+func ParseASCII(r io.Reader, opts Options) ([][]model.Tile, error) {
+	rd := New(opts)
+	glyphs := map[rune]model.Tile{rd.startGlyph(): model.TileCorridor}
+	for t := model.TileEmpty; t <= model.TileWall; t++ {
+		glyphs[rd.glyph(t)] = t
+	}
+
+	var lines [][]rune
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := []rune(strings.TrimRight(sc.Text(), "\r"))
+		isMap := len(line) > 0
+		for _, g := range line {
+			if _, ok := glyphs[g]; !ok {
+				isMap = false
+				break
+			}
+		}
+		if isMap {
+			lines = append(lines, line)
+		} else if len(lines) > 0 {
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("no map found")
+	}
+
+	// Wide maps have a space after every glyph.
+	wide := true
+	for _, line := range lines {
+		for i := 1; i < len(line) && wide; i += 2 {
+			wide = line[i] == ' '
+		}
+	}
+	width := 0
+	for i, line := range lines {
+		if wide {
+			narrow := make([]rune, 0, (len(line)+1)/2)
+			for j := 0; j < len(line); j += 2 {
+				narrow = append(narrow, line[j])
+			}
+			lines[i] = narrow
+		}
+		width = max(width, len(lines[i]))
+	}
+
+	rows := make([][]model.Tile, len(lines))
+	for i, line := range lines {
+		rows[i] = make([]model.Tile, width)
+		for j, g := range line {
+			rows[i][j] = glyphs[g]
+		}
+	}
+	return rows, nil
+}
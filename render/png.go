@@ -0,0 +1,191 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Outline selects how rooms are outlined in images.
+type Outline int
+
+const (
+	// OutlineNone draws no room outlines.
+	OutlineNone Outline = iota
+	// OutlineBox outlines each room's bounding box.
+	OutlineBox
+	// OutlineShape outlines the floor and door tiles inside each room's
+	// bounding box, which follows the room's shape.
+	OutlineShape
+)
+
+var outlineName = map[Outline]string{
+	OutlineNone:  "none",
+	OutlineBox:   "box",
+	OutlineShape: "shape",
+}
+
+func (o Outline) String() string {
+	if name, ok := outlineName[o]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseOutline returns the Outline named s, ignoring case.
+func ParseOutline(s string) (Outline, bool) {
+	for o, name := range outlineName {
+		if strings.EqualFold(name, s) {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// ImageOptions controls how Image draws a dungeon.
+type ImageOptions struct {
+	// Scale is the size of a tile in pixels; 0 selects 8.
+	Scale int
+	// Theme colors each tile and the start markers; nil selects
+	// Themes["default"]. Tiles missing from the theme are left black.
+	Theme *Theme
+	// Grid draws a one pixel line in GridColor between tiles.
+	Grid      bool
+	GridColor Color
+	// Starts marks every start with a square in Theme.Start.
+	Starts bool
+	// Outline draws room outlines in OutlineColor.
+	Outline      Outline
+	OutlineColor Color
+}
+
+// DefaultImageOptions draws 8 pixel tiles with start markers and shape
+// outlines.
+var DefaultImageOptions = ImageOptions{
+	Scale:        8,
+	GridColor:    Color{R: 0x20, G: 0x20, B: 0x20},
+	Starts:       true,
+	Outline:      OutlineShape,
+	OutlineColor: Color{R: 0xff, G: 0xff, B: 0xff},
+}
+
+func (c Color) rgba() color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff}
+}
+
+// Image draws d with one Scale-sized square per tile. As in text output the
+// highest Y is at the top.
+func Image(d *model.Dungeon, opts ImageOptions) *image.RGBA {
+	s := opts.Scale
+	if s <= 0 {
+		s = 8
+	}
+	theme := opts.Theme
+	if theme == nil {
+		t := Themes["default"]
+		theme = &t
+	}
+
+	g := d.Grid
+	w, h := int(g.Width()), int(g.Height())
+	img := image.NewRGBA(image.Rect(0, 0, w*s, h*s))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	// tileRect returns the pixel rectangle of c.
+	tileRect := func(c model.Cell) image.Rectangle {
+		x := int(c.X-g.MinX) * s
+		y := int(g.MaxY-c.Y) * s
+		return image.Rect(x, y, x+s, y+s)
+	}
+	fill := func(r image.Rectangle, c Color) {
+		draw.Draw(img, r, image.NewUniform(c.rgba()), image.Point{}, draw.Src)
+	}
+
+	for y := g.MinY; y <= g.MaxY; y++ {
+		for x := g.MinX; x <= g.MaxX; x++ {
+			c := model.Cell{X: x, Y: y}
+			if col, ok := theme.Tiles[d.At(c)]; ok {
+				fill(tileRect(c), col)
+			}
+		}
+	}
+
+	if opts.Grid && s > 1 {
+		for x := 0; x <= w; x++ {
+			fill(image.Rect(x*s, 0, x*s+1, h*s), opts.GridColor)
+		}
+		for y := 0; y <= h; y++ {
+			fill(image.Rect(0, y*s, w*s, y*s+1), opts.GridColor)
+		}
+	}
+
+	// Outlines are drawn on the inner edge of the outlined tiles so they
+	// never leave the image.
+	line := max(1, s/8)
+	switch opts.Outline {
+	case OutlineBox:
+		for _, r := range d.Rooms {
+			box := tileRect(r.TopLeft).Union(tileRect(r.BottomRight)).Intersect(img.Bounds())
+			if box.Empty() {
+				continue
+			}
+			fill(image.Rect(box.Min.X, box.Min.Y, box.Max.X, box.Min.Y+line), opts.OutlineColor)
+			fill(image.Rect(box.Min.X, box.Max.Y-line, box.Max.X, box.Max.Y), opts.OutlineColor)
+			fill(image.Rect(box.Min.X, box.Min.Y, box.Min.X+line, box.Max.Y), opts.OutlineColor)
+			fill(image.Rect(box.Max.X-line, box.Min.Y, box.Max.X, box.Max.Y), opts.OutlineColor)
+		}
+	case OutlineShape:
+		for _, r := range d.Rooms {
+			inRoom := func(c model.Cell) bool {
+				t := d.At(c)
+				return r.Contains(c) && d.InBounds(c) && (t == model.TileRoomFloor || t == model.TileDoor)
+			}
+			for y := r.TopLeft.Y; y <= r.BottomRight.Y; y++ {
+				for x := r.TopLeft.X; x <= r.BottomRight.X; x++ {
+					c := model.Cell{X: x, Y: y}
+					if !inRoom(c) {
+						continue
+					}
+					tr := tileRect(c)
+					if !inRoom(model.Cell{X: x, Y: y + 1}) {
+						fill(image.Rect(tr.Min.X, tr.Min.Y, tr.Max.X, tr.Min.Y+line), opts.OutlineColor)
+					}
+					if !inRoom(model.Cell{X: x, Y: y - 1}) {
+						fill(image.Rect(tr.Min.X, tr.Max.Y-line, tr.Max.X, tr.Max.Y), opts.OutlineColor)
+					}
+					if !inRoom(model.Cell{X: x - 1, Y: y}) {
+						fill(image.Rect(tr.Min.X, tr.Min.Y, tr.Min.X+line, tr.Max.Y), opts.OutlineColor)
+					}
+					if !inRoom(model.Cell{X: x + 1, Y: y}) {
+						fill(image.Rect(tr.Max.X-line, tr.Min.Y, tr.Max.X, tr.Max.Y), opts.OutlineColor)
+					}
+				}
+			}
+		}
+	}
+
+	if opts.Starts {
+		for _, st := range d.Starts {
+			if !d.InBounds(st) {
+				continue
+			}
+			inset := s / 4
+			fill(tileRect(st).Inset(inset), theme.Start)
+		}
+	}
+	return img
+}
+
+// WritePNG encodes the Image of d as a PNG to w.
+func WritePNG(w io.Writer, d *model.Dungeon, opts ImageOptions) error {
+	if err := png.Encode(w, Image(d, opts)); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package render_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+func rgb(c render.Color) color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff}
+}
+
+func TestWritePNG(t *testing.T) {
+	d := testDungeon(t)
+	theme := render.Themes["default"]
+	const s = 8
+	grid := render.Color{R: 0x01, G: 0x02, B: 0x03}
+	outline := render.Color{R: 0xfe, G: 0xfd, B: 0xfc}
+
+	// decode writes d as a PNG with opts and reads it back.
+	decode := func(t *testing.T, opts render.ImageOptions) image.Image {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := render.WritePNG(&buf, &d, opts); err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := img.Bounds(), image.Rect(0, 0, 7*s, 4*s); got != want {
+			t.Fatalf("image bounds = %v, want %v", got, want)
+		}
+		return img
+	}
+	// at returns the color of pixel (px, py) of the tile at c, where row 0
+	// of the image is the highest Y.
+	at := func(img image.Image, c model.Cell, px, py int) color.RGBA {
+		x := int(c.X-d.Grid.MinX)*s + px
+		y := int(d.Grid.MaxY-c.Y)*s + py
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+
+	t.Run("tiles", func(t *testing.T) {
+		img := decode(t, render.ImageOptions{Scale: s})
+		for y := d.Grid.MinY; y <= d.Grid.MaxY; y++ {
+			for x := d.Grid.MinX; x <= d.Grid.MaxX; x++ {
+				c := model.Cell{X: x, Y: y}
+				want := rgb(theme.Tiles[d.At(c)])
+				for _, p := range [][2]int{{0, 0}, {s / 2, s / 2}, {s - 1, s - 1}} {
+					if got := at(img, c, p[0], p[1]); got != want {
+						t.Errorf("%v pixel %v = %v, want %v for %s", c, p, got, want, d.At(c))
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("grid", func(t *testing.T) {
+		img := decode(t, render.ImageOptions{Scale: s, Grid: true, GridColor: grid})
+		floor := model.Cell{X: 1, Y: 1}
+		if got := at(img, floor, 0, s/2); got != rgb(grid) {
+			t.Errorf("left edge = %v, want the grid color", got)
+		}
+		if got := at(img, floor, s/2, 0); got != rgb(grid) {
+			t.Errorf("top edge = %v, want the grid color", got)
+		}
+		if got := at(img, floor, s/2, s/2); got != rgb(theme.Tiles[model.TileRoomFloor]) {
+			t.Errorf("centre = %v, want the floor color", got)
+		}
+	})
+
+	t.Run("starts", func(t *testing.T) {
+		img := decode(t, render.ImageOptions{Scale: s, Starts: true})
+		start := d.Starts[0]
+		if got := at(img, start, s/2, s/2); got != rgb(theme.Start) {
+			t.Errorf("start centre = %v, want the start color", got)
+		}
+		if got := at(img, start, 1, 1); got != rgb(theme.Tiles[model.TileCorridor]) {
+			t.Errorf("start corner = %v, want the corridor color", got)
+		}
+	})
+
+	t.Run("outline", func(t *testing.T) {
+		for _, mode := range []render.Outline{render.OutlineBox, render.OutlineShape} {
+			img := decode(t, render.ImageOptions{Scale: s, Outline: mode, OutlineColor: outline})
+			// The room covers 1..2 × 1..2; its top left tile is outlined
+			// on the top and left and its inside is plain floor.
+			topLeft := model.Cell{X: 1, Y: 2}
+			for _, p := range [][2]int{{s / 2, 0}, {0, s / 2}} {
+				if got := at(img, topLeft, p[0], p[1]); got != rgb(outline) {
+					t.Errorf("%s: pixel %v = %v, want the outline color", mode, p, got)
+				}
+			}
+			for _, p := range [][2]int{{s / 2, s / 2}, {s - 1, s - 1}} {
+				if got := at(img, topLeft, p[0], p[1]); got != rgb(theme.Tiles[model.TileRoomFloor]) {
+					t.Errorf("%s: pixel %v = %v, want the floor color", mode, p, got)
+				}
+			}
+			if got := at(img, model.Cell{X: 3, Y: 2}, 0, s/2); got != rgb(theme.Tiles[model.TileDoor]) {
+				t.Errorf("%s: door = %v, want it left unoutlined", mode, got)
+			}
+		}
+	})
+}
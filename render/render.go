@@ -1,42 +1,180 @@
 package render
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/mikegio27/proc-dungeons/model"
 )
 
+// Options controls how a Renderer draws a dungeon.
+type Options struct {
+	// Glyphs overrides the glyph of individual tiles; tiles not listed use
+	// Tile.Rune.
+	Glyphs map[model.Tile]rune
+	// Start is the glyph for corridor starts; 0 selects '*'.
+	Start rune
+	// Wide follows every glyph with a space, which keeps the map roughly
+	// square in most terminal fonts.
+	Wide bool
+	// Border draws a ring of wall glyphs just outside the grid. Starts
+	// break through it so the entrances stay visible.
+	Border bool
+	// Padding adds this many blank cells around the grid (and border).
+	Padding int
+
+	// Color selects ANSI colors; ColorNone writes plain text. Use
+	// DetectColor to pick a mode for a given output.
+	Color ColorMode
+	// Theme is the palette for Color; nil selects Themes["default"].
+	Theme *Theme
+}
+
+// DefaultOptions is the layout DrawDungeon uses: wide cells with a border.
+var DefaultOptions = Options{Wide: true, Border: true}
+
+// Renderer draws dungeons as text.
+type Renderer struct {
+	opts Options
+}
+
+// New returns a Renderer using opts.
+func New(opts Options) *Renderer {
+	return &Renderer{opts: opts}
+}
+
 // DrawDungeon prints a simple ASCII representation of the dungeon to standard output with walls around the rendered grid.
 func DrawDungeon(d *model.Dungeon) {
+	New(DefaultOptions).Render(os.Stdout, d)
+}
+
+// DrawDungeonColor is DrawDungeon in the richest colors standard output
+// supports, falling back to plain text as DetectColor describes.
+func DrawDungeonColor(d *model.Dungeon) {
+	opts := DefaultOptions
+	opts.Color = DetectColor(os.Stdout)
+	New(opts).Render(os.Stdout, d)
+}
 
+// String returns the rendering of d.
+func (r *Renderer) String(d *model.Dungeon) string {
+	var sb strings.Builder
+	r.Render(&sb, d)
+	return sb.String()
+}
+
+// Render writes d to w, one line per row with the highest Y first.
+func (r *Renderer) Render(w io.Writer, d *model.Dungeon) error {
 	g := d.Grid
 	starts := make(map[model.Cell]bool, len(d.Starts))
 	for _, s := range d.Starts {
 		starts[s] = true
 	}
 
-	for y := g.MaxY + 1; y >= g.MinY-1; y-- {
-		for x := g.MinX - 1; x <= g.MaxX+1; x++ {
-			c := model.Cell{X: x, Y: y}
-			fmt.Printf("%c ", glyphAt(d, c, starts))
+	margin := int32(r.opts.Padding)
+	if r.opts.Border {
+		margin++
+	}
+
+	bw := bufio.NewWriter(w)
+	startStyle := r.startStyle()
+	cur := ""
+	setStyle := func(style string) {
+		if style != cur {
+			if style == "" {
+				bw.WriteString(sgrReset)
+			} else {
+				bw.WriteString(style)
+			}
+			cur = style
+		}
+	}
+	for y := g.MaxY + margin; y >= g.MinY-margin; y-- {
+		for x := g.MinX - margin; x <= g.MaxX+margin; x++ {
+			glyph, style := r.cellAt(d, model.Cell{X: x, Y: y}, starts)
+			setStyle(style)
+			bw.WriteRune(glyph)
+			if r.opts.Wide {
+				if style != "" && style == startStyle {
+					// Keep the reverse video highlight to the glyph itself.
+					setStyle("")
+				}
+				bw.WriteByte(' ')
+			}
 		}
-		fmt.Println()
+		setStyle("")
+		bw.WriteByte('\n')
 	}
+	return bw.Flush()
 }
 
-func glyphAt(d *model.Dungeon, c model.Cell, starts map[model.Cell]bool) rune {
+func (r *Renderer) glyph(t model.Tile) rune {
+	if g, ok := r.opts.Glyphs[t]; ok {
+		return g
+	}
+	return t.Rune()
+}
+
+func (r *Renderer) startGlyph() rune {
+	if r.opts.Start != 0 {
+		return r.opts.Start
+	}
+	return '*'
+}
+
+// cellAt returns the glyph for c and the escape sequence to draw it with,
+// which is empty without color.
+func (r *Renderer) cellAt(d *model.Dungeon, c model.Cell, starts map[model.Cell]bool) (rune, string) {
 	if starts[c] {
-		return '*'
+		return r.startGlyph(), r.startStyle()
 	}
 
 	if !d.InBounds(c) {
+		if !r.opts.Border || !onBorder(d.Grid, c) {
+			return ' ', ""
+		}
 		if adjacentToStart(c, starts) {
-			return '*'
+			return r.startGlyph(), r.startStyle()
 		}
-		return model.TileWall.Rune()
+		return r.glyph(model.TileWall), r.tileStyle(model.TileWall)
 	}
 
-	return d.At(c).Rune()
+	t := d.At(c)
+	return r.glyph(t), r.tileStyle(t)
+}
+
+func (r *Renderer) theme() *Theme {
+	if r.opts.Theme != nil {
+		return r.opts.Theme
+	}
+	t := Themes["default"]
+	return &t
+}
+
+func (r *Renderer) tileStyle(t model.Tile) string {
+	if r.opts.Color == ColorNone {
+		return ""
+	}
+	c, ok := r.theme().Tiles[t]
+	if !ok {
+		return ""
+	}
+	return sgr(r.opts.Color, c, false)
+}
+
+func (r *Renderer) startStyle() string {
+	if r.opts.Color == ColorNone {
+		return ""
+	}
+	return sgr(r.opts.Color, r.theme().Start, true)
+}
+
+// onBorder reports whether c is in the ring of cells just outside g.
+func onBorder(g model.Grid, c model.Cell) bool {
+	return c.X >= g.MinX-1 && c.X <= g.MaxX+1 && c.Y >= g.MinY-1 && c.Y <= g.MaxY+1
 }
 
 func adjacentToStart(c model.Cell, starts map[model.Cell]bool) bool {
@@ -51,3 +189,29 @@ func adjacentToStart(c model.Cell, starts map[model.Cell]bool) bool {
 	}
 	return false
 }
+
+// ParseGlyphs parses a comma-separated list of tile=glyph pairs, such as
+// "Corridor=#,Empty= ", into a glyph map for Options. Each glyph must be a
+// single character.
+func ParseGlyphs(s string) (map[model.Tile]rune, error) {
+	glyphs := make(map[model.Tile]rune)
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		name, glyph, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("want tile=glyph, got %q", pair)
+		}
+		t, ok := model.ParseTile(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown tile %q", name)
+		}
+		runes := []rune(glyph)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("glyph for %s must be one character, got %q", t, glyph)
+		}
+		glyphs[t] = runes[0]
+	}
+	return glyphs, nil
+}
@@ -0,0 +1,112 @@
+package render_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+// testMap is a room with a door and a corridor leading off the map, top
+// row first.
+var testMap = []string{
+	"▒▒▒▒   ",
+	"▒..+###",
+	"▒..▒   ",
+	"▒▒▒▒   ",
+}
+
+// testDungeon returns testMap on the grid 0..6 × 0..3, with its room and a
+// start at the end of the corridor.
+func testDungeon(t *testing.T) model.Dungeon {
+	t.Helper()
+	d := model.NewDungeon(model.Grid{MinX: 0, MaxX: 6, MinY: 0, MaxY: 3})
+	for i, line := range testMap {
+		for x, r := range []rune(line) {
+			tile, ok := model.ParseRune(r)
+			if !ok {
+				t.Fatalf("map rune %q is not a tile", r)
+			}
+			d.Set(model.Cell{X: int32(x), Y: d.Grid.MaxY - int32(i)}, tile)
+		}
+	}
+	d.Rooms = []model.Room{{
+		Shape:       model.Rectangle,
+		TopLeft:     model.Cell{X: 1, Y: 1},
+		BottomRight: model.Cell{X: 2, Y: 2},
+		Doors:       []model.Cell{{X: 3, Y: 2}},
+	}}
+	d.Starts = []model.Cell{{X: 6, Y: 2}}
+	return d
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		opts render.Options
+		want []string
+	}{
+		{"plain", render.Options{}, []string{
+			"▒▒▒▒   ",
+			"▒..+##*",
+			"▒..▒   ",
+			"▒▒▒▒   ",
+		}},
+		{"border", render.Options{Border: true}, []string{
+			"▒▒▒▒▒▒▒▒▒",
+			"▒▒▒▒▒   ▒",
+			"▒▒..+##**",
+			"▒▒..▒   ▒",
+			"▒▒▒▒▒   ▒",
+			"▒▒▒▒▒▒▒▒▒",
+		}},
+		{"wide", render.Options{Wide: true}, []string{
+			"▒ ▒ ▒ ▒       ",
+			"▒ . . + # # * ",
+			"▒ . . ▒       ",
+			"▒ ▒ ▒ ▒       ",
+		}},
+		{"padding", render.Options{Padding: 1}, []string{
+			"         ",
+			" ▒▒▒▒    ",
+			" ▒..+##* ",
+			" ▒..▒    ",
+			" ▒▒▒▒    ",
+			"         ",
+		}},
+		{"glyphs", render.Options{
+			Glyphs: map[model.Tile]rune{model.TileWall: 'W', model.TileCorridor: '='},
+			Start:  '@',
+		}, []string{
+			"WWWW   ",
+			"W..+==@",
+			"W..W   ",
+			"WWWW   ",
+		}},
+	}
+	d := testDungeon(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := render.New(tt.opts).String(&d)
+			if want := strings.Join(tt.want, "\n") + "\n"; got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseGlyphs(t *testing.T) {
+	got, err := render.ParseGlyphs("Wall=W,Corridor= ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[model.TileWall] != 'W' || got[model.TileCorridor] != ' ' {
+		t.Errorf("ParseGlyphs = %v", got)
+	}
+	for _, bad := range []string{"Wall", "Lava=L", "Wall=WW"} {
+		if _, err := render.ParseGlyphs(bad); err == nil {
+			t.Errorf("ParseGlyphs(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// SVGOptions controls how WriteSVG draws a dungeon.
+type SVGOptions struct {
+	// Scale is the size of a tile in SVG user units; 0 selects 16.
+	Scale int
+	// Theme colors each layer and the start markers; nil selects
+	// Themes["default"].
+	Theme *Theme
+	// Labels numbers every room at the centroid of its floor, starting
+	// from 1 in d.Rooms order.
+	Labels bool
+	// Starts marks every start with a circle in Theme.Start.
+	Starts bool
+}
+
+// DefaultSVGOptions draws 16 unit tiles with room numbers and start
+// markers.
+var DefaultSVGOptions = SVGOptions{Scale: 16, Labels: true, Starts: true}
+
+// Corner is a point between tiles in map coordinates: X grows to the right
+// from the grid's MinX edge and Y grows down from its MaxY edge, so tile
+// (MinX, MaxY) spans corners (0, 0) to (1, 1). Image, SVG and other map
+// exports all use this orientation.
+type Corner struct{ X, Y int32 }
+
+type edge struct{ from, to Corner }
+
+// WriteSVG writes d to w as an SVG document. Floors, corridors and walls
+// are separate layers, each a single path traced around the merged tiles
+// rather than one square per tile, so the drawing stays small and scales
+// cleanly. Doors are drawn as door symbols on top.
+func WriteSVG(w io.Writer, d *model.Dungeon, opts SVGOptions) error {
+	s := opts.Scale
+	if s <= 0 {
+		s = 16
+	}
+	theme := opts.Theme
+	if theme == nil {
+		t := Themes["default"]
+		theme = &t
+	}
+	g := d.Grid
+	width, height := int(g.Width())*s, int(g.Height())*s
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	if c, ok := theme.Tiles[model.TileEmpty]; ok {
+		fmt.Fprintf(bw, `<rect id="background" width="%d" height="%d" fill="%s"/>`+"\n", width, height, c)
+	}
+
+	// Doors sit on the floor layer so the door symbols have floor around
+	// them.
+	layers := []struct {
+		id   string
+		tile model.Tile
+		in   func(model.Tile) bool
+	}{
+		{"floor", model.TileRoomFloor, func(t model.Tile) bool { return t == model.TileRoomFloor || t == model.TileDoor }},
+		{"corridors", model.TileCorridor, func(t model.Tile) bool { return t == model.TileCorridor }},
+		{"walls", model.TileWall, func(t model.Tile) bool { return t == model.TileWall }},
+	}
+	for _, l := range layers {
+		c, ok := theme.Tiles[l.tile]
+		if !ok {
+			continue
+		}
+		loops := TraceOutlines(d, func(c model.Cell) bool { return l.in(d.At(c)) })
+		if len(loops) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, `<g id="%s"><path fill="%s" fill-rule="evenodd" d="%s"/></g>`+"\n", l.id, c, pathData(loops, s))
+	}
+
+	writeDoors(bw, d, theme, s)
+
+	if opts.Starts {
+		fmt.Fprintln(bw, `<g id="starts">`)
+		for _, st := range d.Starts {
+			if !d.InBounds(st) {
+				continue
+			}
+			x, y := svgCorner(g, st)
+			fmt.Fprintf(bw, `<circle cx="%g" cy="%g" r="%g" fill="%s"/>`+"\n",
+				(float64(x)+0.5)*float64(s), (float64(y)+0.5)*float64(s), float64(s)*0.35, theme.Start)
+		}
+		fmt.Fprintln(bw, `</g>`)
+	}
+
+	if opts.Labels {
+		fmt.Fprintf(bw, `<g id="labels" font-family="sans-serif" font-size="%d" font-weight="bold" text-anchor="middle" dominant-baseline="central" fill="#000000">`+"\n", s)
+		for i, r := range d.Rooms {
+			cx, cy := roomCentroid(d, r)
+			fmt.Fprintf(bw, `<text x="%.1f" y="%.1f">%d</text>`+"\n", cx*float64(s), cy*float64(s), i+1)
+		}
+		fmt.Fprintln(bw, `</g>`)
+	}
+
+	fmt.Fprintln(bw, `</svg>`)
+	return bw.Flush()
+}
+
+// svgCorner returns the top left corner of c in tile coordinates.
+func svgCorner(g model.Grid, c model.Cell) (int32, int32) {
+	tl := TopLeft(g, c)
+	return tl.X, tl.Y
+}
+
+// TopLeft returns the top left Corner of cell c.
+func TopLeft(g model.Grid, c model.Cell) Corner {
+	return Corner{X: c.X - g.MinX, Y: g.MaxY - c.Y}
+}
+
+// TraceOutlines returns the closed outlines of the cells for which in is
+// true, one loop per boundary with the first corner not repeated.
+// Each cell contributes a clockwise edge on every side that borders a cell
+// outside the set; chaining those edges gives outer boundaries clockwise
+// and holes counter-clockwise, which an even-odd fill draws correctly.
+func TraceOutlines(d *model.Dungeon, in func(model.Cell) bool) [][]Corner {
+	g := d.Grid
+	inside := func(c model.Cell) bool { return d.InBounds(c) && in(c) }
+
+	var edges []edge
+	for y := g.MaxY; y >= g.MinY; y-- {
+		for x := g.MinX; x <= g.MaxX; x++ {
+			c := model.Cell{X: x, Y: y}
+			if !inside(c) {
+				continue
+			}
+			px, py := svgCorner(g, c)
+			tl, tr := Corner{px, py}, Corner{px + 1, py}
+			br, bl := Corner{px + 1, py + 1}, Corner{px, py + 1}
+			if !inside(model.Cell{X: x, Y: y + 1}) {
+				edges = append(edges, edge{tl, tr})
+			}
+			if !inside(model.Cell{X: x + 1, Y: y}) {
+				edges = append(edges, edge{tr, br})
+			}
+			if !inside(model.Cell{X: x, Y: y - 1}) {
+				edges = append(edges, edge{br, bl})
+			}
+			if !inside(model.Cell{X: x - 1, Y: y}) {
+				edges = append(edges, edge{bl, tl})
+			}
+		}
+	}
+
+	// from lists the unused edges leaving each corner, in edge order so
+	// the output is the same on every run.
+	from := make(map[Corner][]int)
+	for i, e := range edges {
+		from[e.from] = append(from[e.from], i)
+	}
+	used := make([]bool, len(edges))
+	next := func(p Corner) (int, bool) {
+		for _, i := range from[p] {
+			if !used[i] {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	var loops [][]Corner
+	for i, e := range edges {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		loop := []Corner{e.from}
+		cur := e.to
+		for cur != e.from {
+			loop = append(loop, cur)
+			j, ok := next(cur)
+			if !ok {
+				break
+			}
+			used[j] = true
+			cur = edges[j].to
+		}
+		loops = append(loops, simplify(loop))
+	}
+	return loops
+}
+
+// simplify drops the corners of loop that lie on a straight line between
+// their neighbours.
+func simplify(loop []Corner) []Corner {
+	n := len(loop)
+	if n < 3 {
+		return loop
+	}
+	var out []Corner
+	for i, p := range loop {
+		prev, next := loop[(i+n-1)%n], loop[(i+1)%n]
+		if (prev.X == p.X && p.X == next.X) || (prev.Y == p.Y && p.Y == next.Y) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// pathData formats loops as SVG path data scaled by s.
+func pathData(loops [][]Corner, s int) string {
+	var sb strings.Builder
+	for _, loop := range loops {
+		for i, p := range loop {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&sb, "%s%d %d", cmd, int(p.X)*s, int(p.Y)*s)
+		}
+		sb.WriteString("Z")
+	}
+	return sb.String()
+}
+
+// writeDoors draws each door as a bar across the passage through it, in the
+// door color with a wall colored frame.
+func writeDoors(w io.Writer, d *model.Dungeon, theme *Theme, s int) {
+	fill, ok := theme.Tiles[model.TileDoor]
+	if !ok {
+		return
+	}
+	stroke := theme.Tiles[model.TileWall]
+	fmt.Fprintf(w, `<g id="doors" fill="%s" stroke="%s" stroke-width="%g">`+"\n", fill, stroke, float64(s)/16)
+
+	g := d.Grid
+	for y := g.MaxY; y >= g.MinY; y-- {
+		for x := g.MinX; x <= g.MaxX; x++ {
+			c := model.Cell{X: x, Y: y}
+			if d.At(c) != model.TileDoor {
+				continue
+			}
+			px, py := svgCorner(g, c)
+			fs := float64(s)
+			left, top := float64(px)*fs, float64(py)*fs
+			long, short := fs*0.8, fs*0.3
+			// The bar runs across the way through the door.
+			if d.VerticalPassage(c) {
+				fmt.Fprintf(w, `<rect x="%g" y="%g" width="%g" height="%g"/>`+"\n", left+(fs-long)/2, top+(fs-short)/2, long, short)
+			} else {
+				fmt.Fprintf(w, `<rect x="%g" y="%g" width="%g" height="%g"/>`+"\n", left+(fs-short)/2, top+(fs-long)/2, short, long)
+			}
+		}
+	}
+	fmt.Fprintln(w, `</g>`)
+}
+
+// roomCentroid returns the centre of the floor and door tiles inside r's
+// bounding box in tile coordinates, or the centre of the box when it holds
+// none.
+func roomCentroid(d *model.Dungeon, r model.Room) (float64, float64) {
+	var sx, sy float64
+	n := 0
+	for y := r.TopLeft.Y; y <= r.BottomRight.Y; y++ {
+		for x := r.TopLeft.X; x <= r.BottomRight.X; x++ {
+			c := model.Cell{X: x, Y: y}
+			if t := d.At(c); t != model.TileRoomFloor && t != model.TileDoor {
+				continue
+			}
+			px, py := svgCorner(d.Grid, c)
+			sx += float64(px) + 0.5
+			sy += float64(py) + 0.5
+			n++
+		}
+	}
+	if n == 0 {
+		tlx, tly := svgCorner(d.Grid, model.Cell{X: r.TopLeft.X, Y: r.BottomRight.Y})
+		brx, bry := svgCorner(d.Grid, model.Cell{X: r.BottomRight.X, Y: r.TopLeft.Y})
+		return float64(tlx+brx+1) / 2, float64(tly+bry+1) / 2
+	}
+	return sx / float64(n), sy / float64(n)
+}
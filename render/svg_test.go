@@ -0,0 +1,95 @@
+package render_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"slices"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+func TestWriteSVG(t *testing.T) {
+	d := testDungeon(t)
+	theme := render.Themes["default"]
+	var buf bytes.Buffer
+	if err := render.WriteSVG(&buf, &d, render.DefaultSVGOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	type rect struct {
+		Width  float64 `xml:"width,attr"`
+		Height float64 `xml:"height,attr"`
+	}
+	var svg struct {
+		Width  int `xml:"width,attr"`
+		Height int `xml:"height,attr"`
+		Groups []struct {
+			ID    string `xml:"id,attr"`
+			Fill  string `xml:"fill,attr"`
+			Paths []struct {
+				Fill string `xml:"fill,attr"`
+				D    string `xml:"d,attr"`
+			} `xml:"path"`
+			Rects   []rect `xml:"rect"`
+			Circles []struct {
+				CX float64 `xml:"cx,attr"`
+				CY float64 `xml:"cy,attr"`
+			} `xml:"circle"`
+			Texts []struct {
+				X    string `xml:"x,attr"`
+				Y    string `xml:"y,attr"`
+				Text string `xml:",chardata"`
+			} `xml:"text"`
+		} `xml:"g"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("decode svg: %v", err)
+	}
+	if svg.Width != 7*16 || svg.Height != 4*16 {
+		t.Errorf("svg is %dx%d, want %dx%d", svg.Width, svg.Height, 7*16, 4*16)
+	}
+
+	var ids []string
+	for _, g := range svg.Groups {
+		ids = append(ids, g.ID)
+		switch g.ID {
+		case "floor", "corridors", "walls":
+			if len(g.Paths) != 1 {
+				t.Errorf("%s has %d paths, want 1", g.ID, len(g.Paths))
+			}
+		}
+		switch g.ID {
+		case "floor":
+			// The room and its door merge into one outline, traced
+			// clockwise from the room's top left corner.
+			want := "M16 16L64 16L64 32L48 32L48 48L16 48Z"
+			if p := g.Paths[0]; p.D != want || p.Fill != theme.Tiles[model.TileRoomFloor].String() {
+				t.Errorf("floor path = %q filled %s, want %q", p.D, p.Fill, want)
+			}
+		case "corridors":
+			if want := "M64 16L112 16L112 32L64 32Z"; g.Paths[0].D != want {
+				t.Errorf("corridor path = %q, want %q", g.Paths[0].D, want)
+			}
+		case "doors":
+			// The way through the door runs left to right, so the bar
+			// stands upright.
+			if len(g.Rects) != 1 || g.Rects[0].Height <= g.Rects[0].Width {
+				t.Errorf("doors = %+v, want one upright bar", g.Rects)
+			}
+		case "starts":
+			if len(g.Circles) != 1 || g.Circles[0].CX != 104 || g.Circles[0].CY != 24 {
+				t.Errorf("starts = %+v, want one circle at (104, 24)", g.Circles)
+			}
+		case "labels":
+			if len(g.Texts) != 1 || g.Texts[0].Text != "1" || g.Texts[0].X != "32.0" || g.Texts[0].Y != "32.0" {
+				t.Errorf("labels = %+v, want room 1 at (32, 32)", g.Texts)
+			}
+		}
+	}
+	want := []string{"floor", "corridors", "walls", "doors", "starts", "labels"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("groups = %v, want %v", ids, want)
+	}
+}
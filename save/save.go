@@ -0,0 +1,91 @@
+// Package save reads and writes generated dungeons as versioned JSON,
+// together with the seed and config that produced them, so other tools can
+// use a dungeon without re-running the generator.
+package save
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mikegio27/proc-dungeons/config"
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+// Version is the save file schema version this package reads and writes.
+// The dungeon and config inside carry their own versions.
+const Version = 1
+
+// File is the on-disk layout of a save file.
+type File struct {
+	Version int           `json:"version"`
+	Config  config.File   `json:"config"`
+	Dungeon model.Dungeon `json:"dungeon"`
+}
+
+// New returns a File for d, generated from seed and cfg.
+func New(seed int64, cfg generator.Config, d model.Dungeon) File {
+	return File{
+		Version: Version,
+		Config:  config.FromConfig(seed, cfg),
+		Dungeon: d,
+	}
+}
+
+// Seed returns the seed the dungeon was generated from, or 0 if unknown.
+func (f File) Seed() int64 {
+	if f.Config.Seed == nil {
+		return 0
+	}
+	return *f.Config.Seed
+}
+
+// GeneratorConfig returns the config the dungeon was generated from.
+func (f File) GeneratorConfig() (generator.Config, error) {
+	var cfg generator.Config
+	if err := f.Config.Apply(&cfg); err != nil {
+		return generator.Config{}, err
+	}
+	return cfg, nil
+}
+
+// Write encodes f as JSON to w.
+func Write(w io.Writer, f File) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// Read decodes a save file from r and checks its version. Unknown keys are
+// rejected.
+func Read(r io.Reader) (File, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var f File
+	if err := dec.Decode(&f); err != nil {
+		return File{}, err
+	}
+	switch {
+	case f.Version == 0:
+		return File{}, fmt.Errorf("version is required")
+	case f.Version > Version:
+		return File{}, fmt.Errorf("version %d is newer than the supported version %d", f.Version, Version)
+	}
+	return f, nil
+}
+
+// Load reads the save file at path.
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	f, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
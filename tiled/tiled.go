@@ -0,0 +1,197 @@
+// Package tiled exports dungeons as Tiled maps (https://www.mapeditor.org)
+// in the TMX (XML) and TMJ (JSON) formats, so generated levels can be
+// polished by hand in the editor.
+//
+// Each map has three layers: a "tiles" tile layer with one GID per cell, a
+// "rooms" object layer with one object per model.Room and a "starts" object
+// layer with one point per start. As in the text renderer the highest Y
+// row of the dungeon is the top row of the map.
+package tiled
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+const (
+	mapVersion    = "1.10"
+	tiledVersion  = "1.10.2"
+	layerTiles    = 1
+	layerRooms    = 2
+	layerStarts   = 3
+	nextLayerID   = 4
+	defaultTileWH = 32
+)
+
+// Tileset describes the tileset the map's GIDs refer to. With Source set
+// the map references an external .tsx file; otherwise the tileset is
+// embedded using Name and Image.
+type Tileset struct {
+	FirstGID uint32
+	Source   string
+
+	Name        string
+	Image       string
+	ImageWidth  int
+	ImageHeight int
+	TileCount   int
+	Columns     int
+}
+
+// Options controls the exported map.
+type Options struct {
+	// TileWidth and TileHeight are the tile size in pixels; 0 selects 32.
+	TileWidth  int
+	TileHeight int
+	Tileset    Tileset
+	// GIDs maps each tile to its global tile ID; tiles that are missing or
+	// map to 0 are left blank.
+	GIDs map[model.Tile]uint32
+}
+
+// DefaultOptions references "dungeon.tsx", whose first four tiles are room
+// floor, corridor, door and wall.
+var DefaultOptions = Options{
+	TileWidth:  defaultTileWH,
+	TileHeight: defaultTileWH,
+	Tileset:    Tileset{FirstGID: 1, Source: "dungeon.tsx"},
+	GIDs: map[model.Tile]uint32{
+		model.TileRoomFloor: 1,
+		model.TileCorridor:  2,
+		model.TileDoor:      3,
+		model.TileWall:      4,
+	},
+}
+
+func (o Options) tileSize() (int, int) {
+	w, h := o.TileWidth, o.TileHeight
+	if w <= 0 {
+		w = defaultTileWH
+	}
+	if h <= 0 {
+		h = defaultTileWH
+	}
+	return w, h
+}
+
+// ParseGIDs parses a comma-separated list of tile=gid pairs, such as
+// "Wall=17,Door=9", on top of base.
+func ParseGIDs(s string, base map[model.Tile]uint32) (map[model.Tile]uint32, error) {
+	gids := make(map[model.Tile]uint32, len(base))
+	for t, gid := range base {
+		gids[t] = gid
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("want tile=gid, got %q", pair)
+		}
+		t, ok := model.ParseTile(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown tile %q", name)
+		}
+		gid, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad gid %q for %s", value, t)
+		}
+		gids[t] = uint32(gid)
+	}
+	return gids, nil
+}
+
+// object is a map object in pixel coordinates, shared by both encoders.
+type object struct {
+	id      int
+	name    string
+	typ     string
+	x, y    float64
+	w, h    float64
+	ellipse bool
+	point   bool
+	polygon [][2]float64
+	shape   string
+}
+
+// tiledMap is the format independent content of an exported map.
+type tiledMap struct {
+	width, height int
+	tileW, tileH  int
+	data          []uint32
+	rooms         []object
+	starts        []object
+	nextObjectID  int
+}
+
+func build(d *model.Dungeon, opts Options) tiledMap {
+	g := d.Grid
+	tw, th := opts.tileSize()
+	m := tiledMap{
+		width:  int(g.Width()),
+		height: int(g.Height()),
+		tileW:  tw,
+		tileH:  th,
+	}
+
+	for y := g.MaxY; y >= g.MinY; y-- {
+		for x := g.MinX; x <= g.MaxX; x++ {
+			m.data = append(m.data, opts.GIDs[d.At(model.Cell{X: x, Y: y})])
+		}
+	}
+
+	// px and py return the pixel position of the top left corner of a
+	// cell; py(y) is the top edge of row y.
+	px := func(x int32) float64 { return float64(x-g.MinX) * float64(tw) }
+	py := func(y int32) float64 { return float64(g.MaxY-y) * float64(th) }
+
+	id := 1
+	for i, r := range d.Rooms {
+		left, right := px(r.TopLeft.X), px(r.BottomRight.X+1)
+		top, bottom := py(r.BottomRight.Y), py(r.TopLeft.Y-1)
+		o := object{
+			id:    id,
+			name:  fmt.Sprintf("room %d", i+1),
+			typ:   "room",
+			x:     left,
+			y:     top,
+			w:     right - left,
+			h:     bottom - top,
+			shape: r.Shape.String(),
+		}
+		switch r.Shape {
+		case model.Circle:
+			// Circles use the smaller side of the box as their diameter.
+			side := min(o.w/float64(tw), o.h/float64(th))
+			cw, ch := side*float64(tw), side*float64(th)
+			o.x, o.y = left+(o.w-cw)/2, top+(o.h-ch)/2
+			o.w, o.h = cw, ch
+			o.ellipse = true
+		case model.Triangle:
+			// The apex is on the lowest Y row, which is the bottom of
+			// the map; points are relative to the object position.
+			o.polygon = [][2]float64{{o.w / 2, o.h}, {0, 0}, {o.w, 0}}
+			o.w, o.h = 0, 0
+		}
+		m.rooms = append(m.rooms, o)
+		id++
+	}
+
+	for i, s := range d.Starts {
+		m.starts = append(m.starts, object{
+			id:    id,
+			name:  fmt.Sprintf("start %d", i+1),
+			typ:   "start",
+			x:     px(s.X) + float64(tw)/2,
+			y:     py(s.Y) + float64(th)/2,
+			point: true,
+		})
+		id++
+	}
+	m.nextObjectID = id
+	return m
+}
@@ -0,0 +1,273 @@
+package tiled_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/tiled"
+)
+
+// testDungeon generates a small rooms mode dungeon with every shape.
+func testDungeon(t *testing.T) model.Dungeon {
+	t.Helper()
+	cfg := generator.Config{
+		Grid:         model.Grid{MinX: -30, MaxX: 29, MinY: -12, MaxY: 12},
+		MaxRooms:     10,
+		RoomShapes:   []model.RoomId{model.Rectangle, model.Circle, model.Square, model.Triangle},
+		CorridorW:    1,
+		CorridorBuff: 1,
+	}
+	d, err := generator.New(cfg, 7).Generate(context.Background())
+	if err != nil && !errors.Is(err, generator.ErrUnreachableRoom) {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(d.Rooms) == 0 || len(d.Starts) == 0 {
+		t.Fatalf("got %d rooms and %d starts, want some of each", len(d.Rooms), len(d.Starts))
+	}
+	return d
+}
+
+// decodedObject is an object read back from either format.
+type decodedObject struct {
+	name, typ, shape string
+	x, y, w, h       float64
+	ellipse, point   bool
+	polygon          bool
+}
+
+// decodedMap is the part of an exported map the tests check.
+type decodedMap struct {
+	width, height int
+	data          []uint32
+	rooms, starts []decodedObject
+}
+
+func decodeTMX(t *testing.T, b []byte) decodedMap {
+	t.Helper()
+	var m struct {
+		Width  int `xml:"width,attr"`
+		Height int `xml:"height,attr"`
+		Layer  struct {
+			Width  int `xml:"width,attr"`
+			Height int `xml:"height,attr"`
+			Data   struct {
+				Encoding string `xml:"encoding,attr"`
+				Text     string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"layer"`
+		Groups []struct {
+			Name    string `xml:"name,attr"`
+			Objects []struct {
+				Name       string  `xml:"name,attr"`
+				Type       string  `xml:"type,attr"`
+				X          float64 `xml:"x,attr"`
+				Y          float64 `xml:"y,attr"`
+				Width      float64 `xml:"width,attr"`
+				Height     float64 `xml:"height,attr"`
+				Properties []struct {
+					Name  string `xml:"name,attr"`
+					Value string `xml:"value,attr"`
+				} `xml:"properties>property"`
+				Ellipse *struct{} `xml:"ellipse"`
+				Point   *struct{} `xml:"point"`
+				Polygon *struct{} `xml:"polygon"`
+			} `xml:"object"`
+		} `xml:"objectgroup"`
+	}
+	if err := xml.Unmarshal(b, &m); err != nil {
+		t.Fatalf("decode tmx: %v", err)
+	}
+	if m.Layer.Width != m.Width || m.Layer.Height != m.Height {
+		t.Errorf("layer is %dx%d, map is %dx%d", m.Layer.Width, m.Layer.Height, m.Width, m.Height)
+	}
+	if m.Layer.Data.Encoding != "csv" {
+		t.Errorf("data encoding = %q, want csv", m.Layer.Data.Encoding)
+	}
+	out := decodedMap{width: m.Width, height: m.Height}
+	for _, f := range strings.Split(strings.TrimSpace(m.Layer.Data.Text), ",") {
+		gid, err := strconv.ParseUint(strings.TrimSpace(f), 10, 32)
+		if err != nil {
+			t.Fatalf("tile data: %v", err)
+		}
+		out.data = append(out.data, uint32(gid))
+	}
+	for _, g := range m.Groups {
+		var objs []decodedObject
+		for _, o := range g.Objects {
+			do := decodedObject{
+				name: o.Name, typ: o.Type,
+				x: o.X, y: o.Y, w: o.Width, h: o.Height,
+				ellipse: o.Ellipse != nil, point: o.Point != nil, polygon: o.Polygon != nil,
+			}
+			for _, p := range o.Properties {
+				if p.Name == "shape" {
+					do.shape = p.Value
+				}
+			}
+			objs = append(objs, do)
+		}
+		switch g.Name {
+		case "rooms":
+			out.rooms = objs
+		case "starts":
+			out.starts = objs
+		default:
+			t.Errorf("unexpected object group %q", g.Name)
+		}
+	}
+	return out
+}
+
+func decodeTMJ(t *testing.T, b []byte) decodedMap {
+	t.Helper()
+	var m struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+		Layers []struct {
+			Name    string   `json:"name"`
+			Type    string   `json:"type"`
+			Width   int      `json:"width"`
+			Height  int      `json:"height"`
+			Data    []uint32 `json:"data"`
+			Objects []struct {
+				Name       string  `json:"name"`
+				Type       string  `json:"type"`
+				X          float64 `json:"x"`
+				Y          float64 `json:"y"`
+				Width      float64 `json:"width"`
+				Height     float64 `json:"height"`
+				Ellipse    bool    `json:"ellipse"`
+				Point      bool    `json:"point"`
+				Polygon    []any   `json:"polygon"`
+				Properties []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"properties"`
+			} `json:"objects"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("decode tmj: %v", err)
+	}
+	out := decodedMap{width: m.Width, height: m.Height}
+	for _, l := range m.Layers {
+		if l.Type == "tilelayer" {
+			if l.Width != m.Width || l.Height != m.Height {
+				t.Errorf("layer is %dx%d, map is %dx%d", l.Width, l.Height, m.Width, m.Height)
+			}
+			out.data = l.Data
+			continue
+		}
+		var objs []decodedObject
+		for _, o := range l.Objects {
+			do := decodedObject{
+				name: o.Name, typ: o.Type,
+				x: o.X, y: o.Y, w: o.Width, h: o.Height,
+				ellipse: o.Ellipse, point: o.Point, polygon: o.Polygon != nil,
+			}
+			for _, p := range o.Properties {
+				if p.Name == "shape" {
+					do.shape = p.Value
+				}
+			}
+			objs = append(objs, do)
+		}
+		switch l.Name {
+		case "rooms":
+			out.rooms = objs
+		case "starts":
+			out.starts = objs
+		default:
+			t.Errorf("unexpected layer %q", l.Name)
+		}
+	}
+	return out
+}
+
+func TestWrite(t *testing.T) {
+	d := testDungeon(t)
+	opts := tiled.DefaultOptions
+	g := d.Grid
+
+	tests := []struct {
+		name   string
+		write  func(*bytes.Buffer) error
+		decode func(*testing.T, []byte) decodedMap
+	}{
+		{"tmx", func(b *bytes.Buffer) error { return tiled.WriteTMX(b, &d, opts) }, decodeTMX},
+		{"tmj", func(b *bytes.Buffer) error { return tiled.WriteTMJ(b, &d, opts) }, decodeTMJ},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			m := tt.decode(t, buf.Bytes())
+
+			if m.width != int(g.Width()) || m.height != int(g.Height()) {
+				t.Errorf("map is %dx%d, want %dx%d", m.width, m.height, g.Width(), g.Height())
+			}
+			if len(m.data) != m.width*m.height {
+				t.Fatalf("got %d tiles, want %d", len(m.data), m.width*m.height)
+			}
+			// The top row of the map is the highest Y row of the dungeon.
+			for i, gid := range m.data {
+				c := model.Cell{X: g.MinX + int32(i%m.width), Y: g.MaxY - int32(i/m.width)}
+				if want := opts.GIDs[d.At(c)]; gid != want {
+					t.Fatalf("tile %d at %v = %d, want %d for %s", i, c, gid, want, d.At(c))
+				}
+			}
+
+			if len(m.rooms) != len(d.Rooms) {
+				t.Fatalf("got %d room objects, want %d", len(m.rooms), len(d.Rooms))
+			}
+			for i, r := range d.Rooms {
+				o := m.rooms[i]
+				if o.name != fmt.Sprintf("room %d", i+1) || o.typ != "room" || o.shape != r.Shape.String() {
+					t.Errorf("room %d: got %q of type %q and shape %q", i, o.name, o.typ, o.shape)
+				}
+				if o.ellipse != (r.Shape == model.Circle) || o.polygon != (r.Shape == model.Triangle) {
+					t.Errorf("room %d, a %s: ellipse %v, polygon %v", i, r.Shape, o.ellipse, o.polygon)
+				}
+				if r.Shape == model.Circle {
+					continue
+				}
+				x := float64(r.TopLeft.X-g.MinX) * float64(opts.TileWidth)
+				y := float64(g.MaxY-r.BottomRight.Y) * float64(opts.TileHeight)
+				if o.x != x || o.y != y {
+					t.Errorf("room %d at (%v, %v), want (%v, %v)", i, o.x, o.y, x, y)
+				}
+				if r.Shape == model.Triangle {
+					continue
+				}
+				w := float64(r.BottomRight.X-r.TopLeft.X+1) * float64(opts.TileWidth)
+				h := float64(r.BottomRight.Y-r.TopLeft.Y+1) * float64(opts.TileHeight)
+				if o.w != w || o.h != h {
+					t.Errorf("room %d is %vx%v, want %vx%v", i, o.w, o.h, w, h)
+				}
+			}
+
+			if len(m.starts) != len(d.Starts) {
+				t.Fatalf("got %d start objects, want %d", len(m.starts), len(d.Starts))
+			}
+			for i, s := range d.Starts {
+				o := m.starts[i]
+				x := (float64(s.X-g.MinX) + 0.5) * float64(opts.TileWidth)
+				y := (float64(g.MaxY-s.Y) + 0.5) * float64(opts.TileHeight)
+				if !o.point || o.typ != "start" || o.x != x || o.y != y {
+					t.Errorf("start %d: got %+v, want a point at (%v, %v)", i, o, x, y)
+				}
+			}
+		})
+	}
+}
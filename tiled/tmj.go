@@ -0,0 +1,150 @@
+package tiled
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+type tmjMap struct {
+	Type         string       `json:"type"`
+	Version      string       `json:"version"`
+	TiledVersion string       `json:"tiledversion"`
+	Orientation  string       `json:"orientation"`
+	RenderOrder  string       `json:"renderorder"`
+	Width        int          `json:"width"`
+	Height       int          `json:"height"`
+	TileWidth    int          `json:"tilewidth"`
+	TileHeight   int          `json:"tileheight"`
+	Infinite     bool         `json:"infinite"`
+	NextLayerID  int          `json:"nextlayerid"`
+	NextObjectID int          `json:"nextobjectid"`
+	Tilesets     []tmjTileset `json:"tilesets"`
+	Layers       []tmjLayer   `json:"layers"`
+}
+
+type tmjTileset struct {
+	FirstGID    uint32 `json:"firstgid"`
+	Source      string `json:"source,omitempty"`
+	Name        string `json:"name,omitempty"`
+	TileWidth   int    `json:"tilewidth,omitempty"`
+	TileHeight  int    `json:"tileheight,omitempty"`
+	TileCount   int    `json:"tilecount,omitempty"`
+	Columns     int    `json:"columns,omitempty"`
+	Image       string `json:"image,omitempty"`
+	ImageWidth  int    `json:"imagewidth,omitempty"`
+	ImageHeight int    `json:"imageheight,omitempty"`
+}
+
+type tmjLayer struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	X         int      `json:"x"`
+	Y         int      `json:"y"`
+	Width     int      `json:"width,omitempty"`
+	Height    int      `json:"height,omitempty"`
+	Opacity   float64  `json:"opacity"`
+	Visible   bool     `json:"visible"`
+	Data      []uint32 `json:"data,omitempty"`
+	DrawOrder string   `json:"draworder,omitempty"`
+	// Objects is a pointer so object groups always have the key, even
+	// when empty, and tile layers never do.
+	Objects *[]tmjObject `json:"objects,omitempty"`
+}
+
+type tmjObject struct {
+	ID         int           `json:"id"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	X          float64       `json:"x"`
+	Y          float64       `json:"y"`
+	Width      float64       `json:"width"`
+	Height     float64       `json:"height"`
+	Rotation   float64       `json:"rotation"`
+	Visible    bool          `json:"visible"`
+	Ellipse    bool          `json:"ellipse,omitempty"`
+	Point      bool          `json:"point,omitempty"`
+	Polygon    []tmjPoint    `json:"polygon,omitempty"`
+	Properties []tmjProperty `json:"properties,omitempty"`
+}
+
+type tmjPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type tmjProperty struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// WriteTMJ writes d to w as a Tiled JSON map.
+func WriteTMJ(w io.Writer, d *model.Dungeon, opts Options) error {
+	m := build(d, opts)
+
+	ts := tmjTileset{FirstGID: max(opts.Tileset.FirstGID, 1), Source: opts.Tileset.Source}
+	if ts.Source == "" {
+		ts.Name = opts.Tileset.Name
+		ts.TileWidth, ts.TileHeight = m.tileW, m.tileH
+		ts.TileCount, ts.Columns = opts.Tileset.TileCount, opts.Tileset.Columns
+		ts.Image, ts.ImageWidth, ts.ImageHeight = opts.Tileset.Image, opts.Tileset.ImageWidth, opts.Tileset.ImageHeight
+	}
+
+	out := tmjMap{
+		Type:         "map",
+		Version:      mapVersion,
+		TiledVersion: tiledVersion,
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        m.width,
+		Height:       m.height,
+		TileWidth:    m.tileW,
+		TileHeight:   m.tileH,
+		NextLayerID:  nextLayerID,
+		NextObjectID: m.nextObjectID,
+		Tilesets:     []tmjTileset{ts},
+		Layers: []tmjLayer{
+			{
+				ID: layerTiles, Name: "tiles", Type: "tilelayer",
+				Width: m.width, Height: m.height, Opacity: 1, Visible: true,
+				Data: m.data,
+			},
+			{
+				ID: layerRooms, Name: "rooms", Type: "objectgroup",
+				Opacity: 1, Visible: true, DrawOrder: "topdown",
+				Objects: tmjObjects(m.rooms),
+			},
+			{
+				ID: layerStarts, Name: "starts", Type: "objectgroup",
+				Opacity: 1, Visible: true, DrawOrder: "topdown",
+				Objects: tmjObjects(m.starts),
+			},
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(out)
+}
+
+func tmjObjects(objs []object) *[]tmjObject {
+	out := make([]tmjObject, 0, len(objs))
+	for _, o := range objs {
+		t := tmjObject{
+			ID: o.id, Name: o.name, Type: o.typ,
+			X: o.x, Y: o.y, Width: o.w, Height: o.h,
+			Visible: true, Ellipse: o.ellipse, Point: o.point,
+		}
+		for _, p := range o.polygon {
+			t.Polygon = append(t.Polygon, tmjPoint{X: p[0], Y: p[1]})
+		}
+		if o.shape != "" {
+			t.Properties = []tmjProperty{{Name: "shape", Type: "string", Value: o.shape}}
+		}
+		out = append(out, t)
+	}
+	return &out
+}
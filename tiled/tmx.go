@@ -0,0 +1,186 @@
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mikegio27/proc-dungeons/model"
+)
+
+type tmxMap struct {
+	XMLName      xml.Name         `xml:"map"`
+	Version      string           `xml:"version,attr"`
+	TiledVersion string           `xml:"tiledversion,attr"`
+	Orientation  string           `xml:"orientation,attr"`
+	RenderOrder  string           `xml:"renderorder,attr"`
+	Width        int              `xml:"width,attr"`
+	Height       int              `xml:"height,attr"`
+	TileWidth    int              `xml:"tilewidth,attr"`
+	TileHeight   int              `xml:"tileheight,attr"`
+	Infinite     int              `xml:"infinite,attr"`
+	NextLayerID  int              `xml:"nextlayerid,attr"`
+	NextObjectID int              `xml:"nextobjectid,attr"`
+	Tileset      tmxTileset       `xml:"tileset"`
+	Layer        tmxLayer         `xml:"layer"`
+	ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+}
+
+type tmxTileset struct {
+	FirstGID   uint32    `xml:"firstgid,attr"`
+	Source     string    `xml:"source,attr,omitempty"`
+	Name       string    `xml:"name,attr,omitempty"`
+	TileWidth  int       `xml:"tilewidth,attr,omitempty"`
+	TileHeight int       `xml:"tileheight,attr,omitempty"`
+	TileCount  int       `xml:"tilecount,attr,omitempty"`
+	Columns    int       `xml:"columns,attr,omitempty"`
+	Image      *tmxImage `xml:"image"`
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tmxLayer struct {
+	ID     int     `xml:"id,attr"`
+	Name   string  `xml:"name,attr"`
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   tmxData `xml:"data"`
+}
+
+type tmxData struct {
+	Encoding string `xml:"encoding,attr"`
+	// CSV is only digits, commas and newlines, so it is written as is
+	// rather than escaped.
+	CSV string `xml:",innerxml"`
+}
+
+type tmxObjectGroup struct {
+	ID      int         `xml:"id,attr"`
+	Name    string      `xml:"name,attr"`
+	Objects []tmxObject `xml:"object"`
+}
+
+type tmxObject struct {
+	ID         int            `xml:"id,attr"`
+	Name       string         `xml:"name,attr"`
+	Type       string         `xml:"type,attr"`
+	X          float64        `xml:"x,attr"`
+	Y          float64        `xml:"y,attr"`
+	Width      float64        `xml:"width,attr,omitempty"`
+	Height     float64        `xml:"height,attr,omitempty"`
+	Properties *tmxProperties `xml:"properties"`
+	Ellipse    *struct{}      `xml:"ellipse"`
+	Point      *struct{}      `xml:"point"`
+	Polygon    *tmxPolygon    `xml:"polygon"`
+}
+
+type tmxProperties struct {
+	Property []tmxProperty `xml:"property"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type tmxPolygon struct {
+	Points string `xml:"points,attr"`
+}
+
+// WriteTMX writes d to w as a Tiled TMX map.
+func WriteTMX(w io.Writer, d *model.Dungeon, opts Options) error {
+	m := build(d, opts)
+
+	var csv strings.Builder
+	csv.WriteByte('\n')
+	for i, gid := range m.data {
+		csv.WriteString(strconv.FormatUint(uint64(gid), 10))
+		if i < len(m.data)-1 {
+			csv.WriteByte(',')
+		}
+		if (i+1)%m.width == 0 {
+			csv.WriteByte('\n')
+		}
+	}
+
+	out := tmxMap{
+		Version:      mapVersion,
+		TiledVersion: tiledVersion,
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        m.width,
+		Height:       m.height,
+		TileWidth:    m.tileW,
+		TileHeight:   m.tileH,
+		NextLayerID:  nextLayerID,
+		NextObjectID: m.nextObjectID,
+		Tileset:      tmxTilesetFor(opts.Tileset, m),
+		Layer: tmxLayer{
+			ID:     layerTiles,
+			Name:   "tiles",
+			Width:  m.width,
+			Height: m.height,
+			Data:   tmxData{Encoding: "csv", CSV: csv.String()},
+		},
+		ObjectGroups: []tmxObjectGroup{
+			{ID: layerRooms, Name: "rooms", Objects: tmxObjects(m.rooms)},
+			{ID: layerStarts, Name: "starts", Objects: tmxObjects(m.starts)},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encode tmx: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func tmxTilesetFor(ts Tileset, m tiledMap) tmxTileset {
+	out := tmxTileset{FirstGID: max(ts.FirstGID, 1), Source: ts.Source}
+	if ts.Source != "" {
+		return out
+	}
+	out.Name = ts.Name
+	out.TileWidth, out.TileHeight = m.tileW, m.tileH
+	out.TileCount, out.Columns = ts.TileCount, ts.Columns
+	if ts.Image != "" {
+		out.Image = &tmxImage{Source: ts.Image, Width: ts.ImageWidth, Height: ts.ImageHeight}
+	}
+	return out
+}
+
+func tmxObjects(objs []object) []tmxObject {
+	out := make([]tmxObject, 0, len(objs))
+	for _, o := range objs {
+		t := tmxObject{ID: o.id, Name: o.name, Type: o.typ, X: o.x, Y: o.y, Width: o.w, Height: o.h}
+		if o.shape != "" {
+			t.Properties = &tmxProperties{Property: []tmxProperty{{Name: "shape", Value: o.shape}}}
+		}
+		if o.ellipse {
+			t.Ellipse = &struct{}{}
+		}
+		if o.point {
+			t.Point = &struct{}{}
+		}
+		if o.polygon != nil {
+			pts := make([]string, len(o.polygon))
+			for i, p := range o.polygon {
+				pts[i] = strconv.FormatFloat(p[0], 'f', -1, 64) + "," + strconv.FormatFloat(p[1], 'f', -1, 64)
+			}
+			t.Polygon = &tmxPolygon{Points: strings.Join(pts, " ")}
+		}
+		out = append(out, t)
+	}
+	return out
+}
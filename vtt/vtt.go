@@ -0,0 +1,146 @@
+// Package vtt exports dungeons in the Universal VTT format (.dd2vtt), read
+// by Foundry VTT and other virtual tabletops through import modules.
+//
+// The file holds a PNG of the map, line of sight walls traced around every
+// walkable area, and a portal (door) for each door tile. All positions are
+// in grid units with the origin at the top left of the image, which shows
+// the highest Y row of the dungeon at the top.
+package vtt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+)
+
+// Format is the Universal VTT version written.
+const Format = 0.3
+
+// Options controls the exported file.
+type Options struct {
+	// PixelsPerGrid is the size of a tile in the embedded image; 0
+	// selects 70.
+	PixelsPerGrid int
+	// Image is used for the embedded render; its Scale is replaced by
+	// PixelsPerGrid.
+	Image render.ImageOptions
+}
+
+// DefaultOptions embeds a 70 pixel per tile image, the usual grid size of
+// battle map assets, drawn with render.DefaultImageOptions.
+var DefaultOptions = Options{PixelsPerGrid: 70, Image: render.DefaultImageOptions}
+
+type file struct {
+	Format             float64     `json:"format"`
+	Resolution         resolution  `json:"resolution"`
+	LineOfSight        [][]point   `json:"line_of_sight"`
+	ObjectsLineOfSight [][]point   `json:"objects_line_of_sight"`
+	Portals            []portal    `json:"portals"`
+	Environment        environment `json:"environment"`
+	Lights             []struct{}  `json:"lights"`
+	Image              string      `json:"image"`
+}
+
+type resolution struct {
+	MapOrigin     point `json:"map_origin"`
+	MapSize       point `json:"map_size"`
+	PixelsPerGrid int   `json:"pixels_per_grid"`
+}
+
+type point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type portal struct {
+	Position     point    `json:"position"`
+	Bounds       [2]point `json:"bounds"`
+	Rotation     float64  `json:"rotation"`
+	Closed       bool     `json:"closed"`
+	Freestanding bool     `json:"freestanding"`
+}
+
+type environment struct {
+	BakedLighting bool   `json:"baked_lighting"`
+	AmbientLight  string `json:"ambient_light"`
+}
+
+// Write encodes d as a Universal VTT file to w.
+func Write(w io.Writer, d *model.Dungeon, opts Options) error {
+	ppg := opts.PixelsPerGrid
+	if ppg <= 0 {
+		ppg = 70
+	}
+	imgOpts := opts.Image
+	imgOpts.Scale = ppg
+	var img bytes.Buffer
+	if err := render.WritePNG(&img, d, imgOpts); err != nil {
+		return err
+	}
+
+	g := d.Grid
+	out := file{
+		Format: Format,
+		Resolution: resolution{
+			MapSize:       point{X: float64(g.Width()), Y: float64(g.Height())},
+			PixelsPerGrid: ppg,
+		},
+		LineOfSight:        walls(d),
+		ObjectsLineOfSight: [][]point{},
+		Portals:            portals(d),
+		Environment:        environment{AmbientLight: "ffffffff"},
+		Lights:             []struct{}{},
+		Image:              base64.StdEncoding.EncodeToString(img.Bytes()),
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// walls traces the boundary between walkable tiles and walls (or the empty
+// space around the map) as closed polylines. Door tiles count as walkable,
+// so each door leaves a gap in the wall that its portal fills.
+func walls(d *model.Dungeon) [][]point {
+	loops := render.TraceOutlines(d, func(c model.Cell) bool { return d.At(c).Walkable() })
+	lines := make([][]point, 0, len(loops))
+	for _, loop := range loops {
+		line := make([]point, 0, len(loop)+1)
+		for _, c := range loop {
+			line = append(line, point{X: float64(c.X), Y: float64(c.Y)})
+		}
+		lines = append(lines, append(line, line[0]))
+	}
+	return lines
+}
+
+// portals returns one closed portal per door tile, spanning the tile
+// across the way through it.
+func portals(d *model.Dungeon) []portal {
+	var out []portal
+	g := d.Grid
+	for y := g.MaxY; y >= g.MinY; y-- {
+		for x := g.MinX; x <= g.MaxX; x++ {
+			c := model.Cell{X: x, Y: y}
+			if d.At(c) != model.TileDoor {
+				continue
+			}
+			tl := render.TopLeft(g, c)
+			cx, cy := float64(tl.X)+0.5, float64(tl.Y)+0.5
+			p := portal{Position: point{X: cx, Y: cy}, Closed: true}
+			if d.VerticalPassage(c) {
+				p.Bounds = [2]point{{X: cx - 0.5, Y: cy}, {X: cx + 0.5, Y: cy}}
+			} else {
+				p.Bounds = [2]point{{X: cx, Y: cy - 0.5}, {X: cx, Y: cy + 0.5}}
+				p.Rotation = math.Pi / 2
+			}
+			out = append(out, p)
+		}
+	}
+	if out == nil {
+		out = []portal{}
+	}
+	return out
+}
@@ -0,0 +1,164 @@
+package vtt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"testing"
+
+	"github.com/mikegio27/proc-dungeons/generator"
+	"github.com/mikegio27/proc-dungeons/model"
+	"github.com/mikegio27/proc-dungeons/render"
+	"github.com/mikegio27/proc-dungeons/vtt"
+)
+
+type point struct{ X, Y float64 }
+
+// dd2vtt is the part of a Universal VTT file the test checks.
+type dd2vtt struct {
+	Format     float64 `json:"format"`
+	Resolution struct {
+		MapOrigin     point `json:"map_origin"`
+		MapSize       point `json:"map_size"`
+		PixelsPerGrid int   `json:"pixels_per_grid"`
+	} `json:"resolution"`
+	LineOfSight [][]point `json:"line_of_sight"`
+	Portals     []struct {
+		Position point    `json:"position"`
+		Bounds   [2]point `json:"bounds"`
+		Closed   bool     `json:"closed"`
+	} `json:"portals"`
+	Image string `json:"image"`
+}
+
+func TestWrite(t *testing.T) {
+	cfg := generator.Config{
+		Grid:         model.Grid{MinX: -30, MaxX: 29, MinY: -12, MaxY: 12},
+		MaxRooms:     10,
+		RoomShapes:   []model.RoomId{model.Rectangle, model.Circle, model.Square, model.Triangle},
+		CorridorW:    1,
+		CorridorBuff: 1,
+	}
+	d, err := generator.New(cfg, 7).Generate(context.Background())
+	if err != nil && !errors.Is(err, generator.ErrUnreachableRoom) {
+		t.Fatalf("Generate: %v", err)
+	}
+	g := d.Grid
+	w, h := g.Width(), g.Height()
+
+	var buf bytes.Buffer
+	opts := vtt.Options{PixelsPerGrid: 10, Image: render.DefaultImageOptions}
+	if err := vtt.Write(&buf, &d, opts); err != nil {
+		t.Fatal(err)
+	}
+	var f dd2vtt
+	if err := json.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatalf("decode dd2vtt: %v", err)
+	}
+
+	if f.Format != vtt.Format {
+		t.Errorf("format = %v, want %v", f.Format, vtt.Format)
+	}
+	r := f.Resolution
+	if r.MapOrigin != (point{}) || r.MapSize != (point{float64(w), float64(h)}) || r.PixelsPerGrid != 10 {
+		t.Errorf("resolution = %+v, want a %dx%d map at 10 pixels per grid", r, w, h)
+	}
+	b, err := base64.StdEncoding.DecodeString(f.Image)
+	if err != nil {
+		t.Fatalf("image: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("image: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != int(w)*10 || size.Y != int(h)*10 {
+		t.Errorf("image is %v, want %dx%d", size, w*10, h*10)
+	}
+
+	// walkable reports whether the tile at column x and row y of the map is
+	// walkable; the space around the map is not.
+	walkable := func(x, y int32) bool {
+		c := model.Cell{X: g.MinX + x, Y: g.MaxY - y}
+		return d.InBounds(c) && d.At(c).Walkable()
+	}
+	// Every unit edge between a walkable tile and anything else must be in
+	// exactly one wall, and every wall must run along such edges.
+	type unit struct {
+		x, y       int32
+		horizontal bool
+	}
+	want := make(map[unit]bool)
+	for y := int32(0); y <= int32(h); y++ {
+		for x := int32(0); x <= int32(w); x++ {
+			if walkable(x, y) != walkable(x, y-1) {
+				want[unit{x, y, true}] = true
+			}
+			if walkable(x, y) != walkable(x-1, y) {
+				want[unit{x, y, false}] = true
+			}
+		}
+	}
+	seen := make(map[unit]bool)
+	for i, line := range f.LineOfSight {
+		if len(line) < 5 || line[0] != line[len(line)-1] {
+			t.Errorf("wall %d is not a closed loop: %v", i, line)
+			continue
+		}
+		for j := 1; j < len(line); j++ {
+			a, b := line[j-1], line[j]
+			if a.X != b.X && a.Y != b.Y {
+				t.Errorf("wall %d: segment %v-%v is not axis aligned", i, a, b)
+				continue
+			}
+			x0, x1 := int32(min(a.X, b.X)), int32(max(a.X, b.X))
+			y0, y1 := int32(min(a.Y, b.Y)), int32(max(a.Y, b.Y))
+			for x := x0; x < x1; x++ {
+				u := unit{x, y0, true}
+				if !want[u] || seen[u] {
+					t.Errorf("wall %d: edge at (%d, %d) is not a lone walkable boundary", i, x, y0)
+				}
+				seen[u] = true
+			}
+			for y := y0; y < y1; y++ {
+				u := unit{x0, y, false}
+				if !want[u] || seen[u] {
+					t.Errorf("wall %d: edge at (%d, %d) is not a lone walkable boundary", i, x0, y)
+				}
+				seen[u] = true
+			}
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("walls cover %d of %d boundary edges", len(seen), len(want))
+	}
+
+	var doors []model.Cell
+	for y := g.MaxY; y >= g.MinY; y-- {
+		for x := g.MinX; x <= g.MaxX; x++ {
+			if c := (model.Cell{X: x, Y: y}); d.At(c) == model.TileDoor {
+				doors = append(doors, c)
+			}
+		}
+	}
+	if len(doors) == 0 || len(f.Portals) != len(doors) {
+		t.Fatalf("got %d portals for %d doors", len(f.Portals), len(doors))
+	}
+	for i, c := range doors {
+		p := f.Portals[i]
+		centre := point{float64(c.X-g.MinX) + 0.5, float64(g.MaxY-c.Y) + 0.5}
+		if p.Position != centre || !p.Closed {
+			t.Errorf("portal %d = %+v, want a closed portal at %v", i, p, centre)
+		}
+		// The portal spans the door across the way through it.
+		across := point{centre.X, centre.Y - 0.5}
+		if d.VerticalPassage(c) {
+			across = point{centre.X - 0.5, centre.Y}
+		}
+		if p.Bounds[0] != across {
+			t.Errorf("portal %d bounds = %v, want to start at %v", i, p.Bounds, across)
+		}
+	}
+}